@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/logrusutil"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+	"github.com/opensourceways/yabot/gitee/plugins/builtin"
+)
+
+type options struct {
+	pluginConfig string
+}
+
+func (o *options) Validate() error {
+	if o.pluginConfig == "" {
+		return fmt.Errorf("--plugin-config must be set")
+	}
+	return nil
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	var o options
+	fs.StringVar(&o.pluginConfig, "plugin-config", "/etc/plugins/plugins.yaml", "Path to plugin config file.")
+	fs.Parse(args)
+	return o
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	// The clients are not needed to check the config.
+	ps := builtin.Plugins(builtin.Clients{})
+
+	agent := plugins.NewConfigAgent()
+	for _, p := range ps {
+		agent.RegisterPluginConfigBuilder(p.PluginName(), p.NewPluginConfig)
+	}
+
+	// Each PluginConfig is validated when loading.
+	if err := agent.Load(o.pluginConfig, false, nil); err != nil {
+		logrus.WithError(err).Fatal("Error loading plugin config.")
+	}
+
+	if err := check(agent.Config(), ps); err != nil {
+		for _, e := range err.(utilerrors.Aggregate).Errors() {
+			logrus.Error(e)
+		}
+		logrus.Fatal("Invalid plugin config.")
+	}
+	logrus.Info("Plugin config is valid.")
+}
+
+func check(c *plugins.Configurations, ps []plugins.Plugin) error {
+	names := sets.NewString()
+	for _, p := range ps {
+		names.Insert(p.PluginName())
+	}
+
+	var errs []error
+	errs = append(errs, checkUnknownPlugins(c, names)...)
+	errs = append(errs, checkDuplicatedPlugins(c)...)
+	errs = append(errs, checkExternalPlugins(c)...)
+	errs = append(errs, checkEnabledRepos(c, names)...)
+	return utilerrors.NewAggregate(errs)
+}
+
+func checkUnknownPlugins(c *plugins.Configurations, names sets.String) []error {
+	var errs []error
+	for _, repo := range sets.StringKeySet(c.Plugins).List() {
+		for _, p := range c.Plugins[repo].Names() {
+			if !names.Has(p) {
+				errs = append(errs, fmt.Errorf("%s: unknown plugin: %s", repo, p))
+			}
+		}
+	}
+	return errs
+}
+
+// checkDuplicatedPlugins finds the plugins enabled for both an org and one of
+// its repos which is not excluded, or more than once for the same org or repo.
+func checkDuplicatedPlugins(c *plugins.Configurations) []error {
+	var errs []error
+	for _, repo := range sets.StringKeySet(c.Plugins).List() {
+		enabled := sets.NewString()
+		for _, p := range c.Plugins[repo].Names() {
+			if enabled.Has(p) {
+				errs = append(errs, fmt.Errorf("%s: plugin %s is enabled more than once", repo, p))
+			}
+			enabled.Insert(p)
+		}
+
+		v := strings.SplitN(repo, "/", 2)
+		if len(v) != 2 {
+			continue
+		}
+
+		org, ok := c.Plugins[v[0]]
+		if !ok || sets.NewString(org.ExcludedRepos...).Has(v[1]) {
+			continue
+		}
+
+		dup := enabled.Intersection(sets.NewString(org.Names()...))
+		for _, p := range dup.List() {
+			errs = append(errs, fmt.Errorf("%s: plugin %s is enabled for both the repo and org %s", repo, p, v[0]))
+		}
+	}
+	return errs
+}
+
+// checkExternalPlugins finds the external plugins which have the same name but
+// different endpoints.
+func checkExternalPlugins(c *plugins.Configurations) []error {
+	endpoints := map[string]sets.String{}
+	for _, repo := range sets.StringKeySet(c.ExternalPlugins).List() {
+		for _, p := range c.ExternalPlugins[repo] {
+			if endpoints[p.Name] == nil {
+				endpoints[p.Name] = sets.NewString()
+			}
+			endpoints[p.Name].Insert(p.Endpoint)
+		}
+	}
+
+	var errs []error
+	for _, name := range sets.StringKeySet(endpoints).List() {
+		if v := endpoints[name]; v.Len() > 1 {
+			errs = append(errs, fmt.Errorf("external plugin %s has different endpoints: %s", name, strings.Join(v.List(), ", ")))
+		}
+	}
+	return errs
+}
+
+// checkEnabledRepos checks that each org and repo enabling a plugin has the
+// configuration required by the plugin.
+func checkEnabledRepos(c *plugins.Configurations, names sets.String) []error {
+	var errs []error
+	for _, name := range names.List() {
+		checker, ok := c.GetPluginConfig(name).(plugins.EnabledReposChecker)
+		if !ok {
+			continue
+		}
+
+		orgs, repos, _ := c.EnabledReposForPlugin(name)
+		sort.Strings(orgs)
+		sort.Strings(repos)
+		if err := checker.CheckEnabledRepos(orgs, repos); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
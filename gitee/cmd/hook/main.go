@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/test-infra/pkg/flagutil"
 	prowv1 "k8s.io/test-infra/prow/client/clientset/versioned/typed/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
@@ -44,6 +45,7 @@ import (
 	"github.com/opensourceways/yabot/gitee/gitee"
 	"github.com/opensourceways/yabot/gitee/hook"
 	"github.com/opensourceways/yabot/gitee/plugins"
+	"github.com/opensourceways/yabot/gitee/plugins/freeze"
 )
 
 type options struct {
@@ -61,6 +63,13 @@ type options struct {
 
 	webhookSecretFile string
 	slackTokenFile    string
+
+	recordDir     string
+	deadLetterDir string
+
+	streamBufferSize int
+
+	pluginSyncPeriod time.Duration
 }
 
 func (o *options) Validate() error {
@@ -89,6 +98,10 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file", "/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
 	fs.StringVar(&o.slackTokenFile, "slack-token-file", "", "Path to the file containing the Slack token to use.")
+	fs.StringVar(&o.recordDir, "record-dir", "", "Path to the directory where the validated events will be recorded. Recording is disabled if empty.")
+	fs.StringVar(&o.deadLetterDir, "dead-letter-dir", "", "Path to the directory where the events failed to be delivered to external plugins will be saved. Nothing is saved if empty.")
+	fs.IntVar(&o.streamBufferSize, "stream-buffer-size", 1000, "The number of latest events kept for the external plugins delivered by stream.")
+	fs.DurationVar(&o.pluginSyncPeriod, "plugin-sync-period", 5*time.Minute, "The period at which the plugins working periodically, such as merge, sync the repos enabling them.")
 	fs.Parse(args)
 	return o
 }
@@ -137,8 +150,10 @@ func main() {
 	}
 
 	pm := plugins.NewPluginManager()
+	repoConfigs := plugins.NewRepoConfigCache(pluginAgent, cs.giteeClient)
 
-	if err := initPlugins(configAgent.Config, pluginAgent, pm, cs); err != nil {
+	periodicPlugins, err := initPlugins(configAgent.Config, pluginAgent, repoConfigs, pm, cs)
+	if err != nil {
 		logrus.WithError(err).Fatal("Error loading plugins.")
 	}
 
@@ -148,6 +163,8 @@ func main() {
 
 	defer interrupts.WaitForGracefulShutdown()
 
+	plugins.RunPeriodicPlugins(pluginAgent, repoConfigs, cs.giteeClient, periodicPlugins, o.pluginSyncPeriod)
+
 	// Expose prometheus metrics
 	metrics.ExposeMetrics("gitee-hook", configAgent.Config().PushGateway)
 	pjutil.ServePProf()
@@ -155,7 +172,23 @@ func main() {
 	vf := func(w http.ResponseWriter, r *http.Request) (string, string, []byte, bool, int) {
 		return gitee.ValidateWebhook(w, r, secretAgent.GetTokenGenerator(o.webhookSecretFile))
 	}
-	server := hook.NewServer(originh.NewMetrics(), vf, plugins.NewDispatcher(pluginAgent, pm))
+	var dls *plugins.DeadLetterStore
+	if o.deadLetterDir != "" {
+		dls = plugins.NewDeadLetterStore(o.deadLetterDir)
+	}
+	externalPluginHealth := plugins.NewExternalPluginHealth()
+	eventStream := plugins.NewEventStream(pluginAgent, o.streamBufferSize)
+	dispatcher := plugins.NewDispatcher(pluginAgent, pm, cs.giteeClient, plugins.DispatcherOptions{
+		DeadLetters: dls,
+		Health:      externalPluginHealth,
+		Stream:      eventStream,
+		RepoConfigs: repoConfigs,
+		Mergeable:   freeze.Checker(repoConfigs.PluginConfig),
+	})
+	if o.recordDir != "" {
+		dispatcher = hook.NewRecordingDispatcher(o.recordDir, dispatcher)
+	}
+	server := hook.NewServer(originh.NewMetrics(), vf, dispatcher)
 
 	interrupts.OnInterrupt(func() {
 		server.GracefulShutdown()
@@ -173,11 +206,17 @@ func main() {
 
 	// For /hook, handle a webhook normally.
 	http.Handle("/gitee-hook", server)
+	// Serve the health of external plugin endpoints.
+	http.Handle("/external-plugin-status", externalPluginHealth)
+	// Stream the events to the external plugins which can't be pushed to.
+	http.Handle("/external-plugin-stream", eventStream)
+	// Serve the status of plugin config which is in use.
+	http.Handle("/plugin-config-status", pluginAgent)
 	// Serve plugin help information from /plugin-help.
 	// reset the original plugin help to show the plugins developed for gitee
 	resetPluginHelper(pm)
 	http.Handle("/gitee-plugin-help", pluginhelp.NewHelpAgent(
-		pluginHelperAgent{agent: pluginAgent},
+		pluginHelperAgent{agent: pluginAgent, c: cs.giteeClient},
 		pluginHelperClient{c: cs.giteeClient},
 	))
 
@@ -193,6 +232,7 @@ type clients struct {
 	giteeGitClient git.ClientFactory
 	ownersClient   repoowners.Interface
 	prowJobClient  prowv1.ProwJobInterface
+	kubeClient     kubernetes.Interface
 }
 
 func buildClients(o *options, secretAgent *secret.Agent, pluginAgent *plugins.ConfigAgent, cfg config.Getter) (*clients, error) {
@@ -210,6 +250,11 @@ func buildClients(o *options, secretAgent *secret.Agent, pluginAgent *plugins.Co
 		return nil, fmt.Errorf("Error getting ProwJob client for infrastructure cluster: %w", err)
 	}
 
+	kubeClient, err := o.kubernetes.InfrastructureClusterClient(o.dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting Kubernetes client for infrastructure cluster: %w", err)
+	}
+
 	mdYAMLEnabled := func(org, repo string) bool {
 		return pluginAgent.Config().MDYAMLEnabled(org, repo)
 	}
@@ -231,6 +276,7 @@ func buildClients(o *options, secretAgent *secret.Agent, pluginAgent *plugins.Co
 		giteeGitClient: giteeGitClient,
 		ownersClient:   ownersClient,
 		prowJobClient:  prowJobClient,
+		kubeClient:     kubeClient,
 	}
 	return cs, nil
 }
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 	prowConfig "k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/pluginhelp"
@@ -8,17 +12,28 @@ import (
 
 	"github.com/opensourceways/yabot/gitee/gitee"
 	"github.com/opensourceways/yabot/gitee/plugins"
-	"github.com/opensourceways/yabot/gitee/plugins/cla"
+	"github.com/opensourceways/yabot/gitee/plugins/builtin"
 )
 
-func initPlugins(cfg prowConfig.Getter, agent *plugins.ConfigAgent, pm plugins.Plugins, cs *clients) error {
-	gpc := func(name string) plugins.PluginConfig {
-		return agent.Config().GetPluginConfig(name)
-	}
-
-	var v []plugins.Plugin
-	v = append(v, cla.NewCLA(gpc, cs.giteeClient))
+// initPlugins registers the plugins and returns the ones which also work periodically.
+func initPlugins(cfg prowConfig.Getter, agent *plugins.ConfigAgent, repoConfigs *plugins.RepoConfigCache, pm plugins.Plugins, cs *clients) ([]plugins.PeriodicPlugin, error) {
+	v := builtin.Plugins(builtin.Clients{
+		ProwConfig: cfg,
+		PluginConfig: func(name string) plugins.PluginConfig {
+			return agent.Config().GetPluginConfig(name)
+		},
+		RepoPluginConfig: repoConfigs.PluginConfig,
+		SkipCollaborators: func(org, repo string) bool {
+			return agent.Config().SkipCollaborators(org, repo)
+		},
+		GiteeClient:     cs.giteeClient,
+		GitClient:       cs.giteeGitClient,
+		OwnersClient:    cs.ownersClient,
+		ProwJobClient:   cs.prowJobClient,
+		ConfigMapClient: cs.kubeClient.CoreV1(),
+	})
 
+	var periodic []plugins.PeriodicPlugin
 	for _, i := range v {
 		name := i.PluginName()
 
@@ -26,9 +41,13 @@ func initPlugins(cfg prowConfig.Getter, agent *plugins.ConfigAgent, pm plugins.P
 		pm.RegisterHelper(name, i.HelpProvider)
 
 		agent.RegisterPluginConfigBuilder(name, i.NewPluginConfig)
+
+		if p, ok := i.(plugins.PeriodicPlugin); ok {
+			periodic = append(periodic, p)
+		}
 	}
 
-	return nil
+	return periodic, nil
 }
 
 func genHelpProvider(h plugins.HelpProvider) originp.HelpProvider {
@@ -54,12 +73,49 @@ func resetPluginHelper(pm plugins.Plugins) {
 
 type pluginHelperAgent struct {
 	agent *plugins.ConfigAgent
+	c     gitee.Client
 }
 
 func (p pluginHelperAgent) Config() *originp.Configuration {
 	c := p.agent.Config()
+
+	ps := map[string]sets.String{}
+	add := func(repo string, names []string) {
+		if ps[repo] == nil {
+			ps[repo] = sets.NewString()
+		}
+		ps[repo].Insert(names...)
+	}
+
+	for repo, v := range c.Plugins {
+		if len(v.ExcludedRepos) == 0 {
+			add(repo, v.Names())
+			continue
+		}
+
+		// The org with excluded repos is expanded to the other repos of it,
+		// since the exclusions can't be expressed by the original config.
+		repos, err := p.c.GetRepos(repo)
+		if err != nil {
+			logrus.WithError(err).Errorf("Getting repos in org: %s.", repo)
+			add(repo, v.Names())
+			continue
+		}
+
+		excluded := sets.NewString(v.ExcludedRepos...)
+		for _, item := range repos {
+			if !excluded.Has(strings.TrimPrefix(item.FullName, repo+"/")) {
+				add(item.FullName, v.Names())
+			}
+		}
+	}
+
+	r := make(map[string][]string, len(ps))
+	for repo, v := range ps {
+		r[repo] = v.List()
+	}
 	return &originp.Configuration{
-		Plugins: c.Plugins,
+		Plugins: r,
 	}
 }
 
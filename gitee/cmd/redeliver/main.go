@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/logrusutil"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+type options struct {
+	deadLetterDir string
+	pluginConfig  string
+	plugins       string
+	dryRun        bool
+}
+
+func (o *options) Validate() error {
+	if o.deadLetterDir == "" {
+		return fmt.Errorf("--dead-letter-dir must be set")
+	}
+	return nil
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	var o options
+	fs.StringVar(&o.deadLetterDir, "dead-letter-dir", "", "Path to the directory where the hook saved the dead letters.")
+	fs.StringVar(&o.pluginConfig, "plugin-config", "", "Path to plugin config file. If set, the current endpoint and delivery options of external plugin are used.")
+	fs.StringVar(&o.plugins, "plugins", "", "Comma separated list of external plugin names. Only the dead letters of these plugins are redelivered if set.")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "Only list the dead letters which would be redelivered.")
+	fs.Parse(args)
+	return o
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	var cfg *plugins.Configurations
+	if o.pluginConfig != "" {
+		agent := plugins.NewConfigAgent()
+		if err := agent.Load(o.pluginConfig, false, nil); err != nil {
+			logrus.WithError(err).Fatal("Error loading plugins config.")
+		}
+		cfg = agent.Config()
+	}
+
+	names := sets.NewString()
+	for _, item := range strings.Split(o.plugins, ",") {
+		if v := strings.TrimSpace(item); v != "" {
+			names.Insert(v)
+		}
+	}
+
+	store := plugins.NewDeadLetterStore(o.deadLetterDir)
+	paths, err := store.List()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error listing dead letters.")
+	}
+
+	failed := 0
+	for _, path := range paths {
+		l := logrus.WithField("path", path)
+
+		dl, err := store.Load(path)
+		if err != nil {
+			l.WithError(err).Error("Error loading dead letter.")
+			failed++
+			continue
+		}
+
+		if names.Len() > 0 && !names.Has(dl.Plugin) {
+			continue
+		}
+
+		l = l.WithFields(logrus.Fields{
+			"external-plugin": dl.Plugin,
+			"event-type":      dl.EventType,
+			"event-guid":      dl.EventGUID,
+		})
+
+		if o.dryRun {
+			l.Info("Skip redelivering in dry-run mode.")
+			continue
+		}
+
+		p := externalPlugin(cfg, dl)
+		if err := plugins.DeliverToExternalPlugin(p, dl.Payload, dl.Header); err != nil {
+			l.WithError(err).Error("Error redelivering dead letter.")
+			failed++
+			continue
+		}
+
+		if err := store.Remove(path); err != nil {
+			l.WithError(err).Error("Error removing the redelivered dead letter.")
+		}
+		l.Info("Redelivered dead letter.")
+	}
+
+	if failed > 0 {
+		logrus.Fatalf("%d dead letters failed to be redelivered.", failed)
+	}
+}
+
+// externalPlugin returns the current configuration of the external plugin
+// which the dead letter is for. It falls back to the endpoint recorded in the
+// dead letter if the plugin is not configured any more.
+func externalPlugin(cfg *plugins.Configurations, dl *plugins.DeadLetter) plugins.ExternalPlugin {
+	if cfg != nil {
+		for _, eps := range cfg.ExternalPlugins {
+			for _, p := range eps {
+				if p.Name == dl.Plugin {
+					return p
+				}
+			}
+		}
+	}
+
+	return plugins.ExternalPlugin{Name: dl.Plugin, Endpoint: dl.Endpoint}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/logrusutil"
+
+	"github.com/opensourceways/yabot/gitee/gitee"
+	"github.com/opensourceways/yabot/gitee/hook"
+)
+
+type options struct {
+	recordDir         string
+	hookURL           string
+	webhookSecretFile string
+
+	repos       string
+	eventTypes  string
+	since       string
+	until       string
+	rewriteRepo string
+
+	dryRun bool
+}
+
+func (o *options) Validate() error {
+	if o.recordDir == "" {
+		return fmt.Errorf("--record-dir must be set")
+	}
+	if o.hookURL == "" {
+		return fmt.Errorf("--hook-url must be set")
+	}
+	if o.rewriteRepo != "" && len(strings.Split(o.rewriteRepo, "/")) != 2 {
+		return fmt.Errorf("--rewrite-repo must be in the form of org/repo")
+	}
+	for _, v := range []string{o.since, o.until} {
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("invalid time %q: %v", v, err)
+		}
+	}
+	return nil
+}
+
+func gatherOptions(fs *flag.FlagSet, args ...string) options {
+	var o options
+	fs.StringVar(&o.recordDir, "record-dir", "", "Path to the directory where the hook recorded the events.")
+	fs.StringVar(&o.hookURL, "hook-url", "http://localhost:8888/gitee-hook", "URL of the running gitee hook.")
+	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file", "/etc/webhook/hmac", "Path to the file containing the HMAC secret of the hook.")
+
+	fs.StringVar(&o.repos, "repos", "", "Comma separated list of org/repo. Only the events of these repos are replayed if set.")
+	fs.StringVar(&o.eventTypes, "event-types", "", "Comma separated list of event types, such as 'Note Hook'. Only these events are replayed if set.")
+	fs.StringVar(&o.since, "since", "", "Only replay the events recorded at or after this time, in RFC3339 format.")
+	fs.StringVar(&o.until, "until", "", "Only replay the events recorded before this time, in RFC3339 format.")
+	fs.StringVar(&o.rewriteRepo, "rewrite-repo", "", "If set as org/repo, the repository of every replayed event will be rewritten to it.")
+
+	fs.BoolVar(&o.dryRun, "dry-run", false, "Only list the events which would be replayed.")
+	fs.Parse(args)
+	return o
+}
+
+func main() {
+	logrusutil.ComponentInit()
+
+	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
+	if err := o.Validate(); err != nil {
+		logrus.WithError(err).Fatal("Invalid options")
+	}
+
+	secret, err := ioutil.ReadFile(o.webhookSecretFile)
+	if err != nil && !o.dryRun {
+		logrus.WithError(err).Fatal("Error reading hmac secret.")
+	}
+	secret = bytes.TrimSpace(secret)
+
+	events, err := hook.LoadRecordedEvents(o.recordDir)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error loading recorded events.")
+	}
+
+	events = o.filter(events)
+	logrus.Infof("%d events will be replayed.", len(events))
+
+	failed := 0
+	for i := range events {
+		e := &events[i]
+
+		l := logrus.WithFields(logrus.Fields{
+			"event-type": e.EventType,
+			"event-guid": e.EventGUID,
+			"repo":       e.Repo,
+		})
+
+		if o.dryRun {
+			l.Info("Skip replaying in dry-run mode.")
+			continue
+		}
+
+		if err := replay(o.hookURL, secret, e, o.rewriteRepo); err != nil {
+			l.WithError(err).Error("Error replaying event.")
+			failed++
+		} else {
+			l.Info("Replayed event.")
+		}
+	}
+
+	if failed > 0 {
+		logrus.Fatalf("%d events failed to be replayed.", failed)
+	}
+}
+
+func (o *options) filter(events []hook.RecordedEvent) []hook.RecordedEvent {
+	repos := splitList(o.repos)
+	eventTypes := splitList(o.eventTypes)
+
+	var since, until time.Time
+	if o.since != "" {
+		since, _ = time.Parse(time.RFC3339, o.since)
+	}
+	if o.until != "" {
+		until, _ = time.Parse(time.RFC3339, o.until)
+	}
+
+	r := make([]hook.RecordedEvent, 0, len(events))
+	for _, e := range events {
+		if repos.Len() > 0 && !repos.Has(e.Repo) {
+			continue
+		}
+		if eventTypes.Len() > 0 && !eventTypes.Has(e.EventType) {
+			continue
+		}
+		if !since.IsZero() && e.RecordedAt.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !e.RecordedAt.Before(until) {
+			continue
+		}
+		r = append(r, e)
+	}
+	return r
+}
+
+func replay(hookURL string, secret []byte, e *hook.RecordedEvent, rewriteRepo string) error {
+	payload := []byte(e.Payload)
+	if rewriteRepo != "" && e.Repo != "" && e.Repo != rewriteRepo {
+		v, err := rewritePayloadRepo(payload, e.Repo, rewriteRepo)
+		if err != nil {
+			return err
+		}
+		payload = v
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hookURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header = e.Header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gitee-Event", e.EventType)
+	req.Header.Set("X-Gitee-Timestamp", e.EventGUID)
+	req.Header.Set("X-Gitee-Token", gitee.PayloadSignature(e.EventGUID, secret))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	rb, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("response has status %q and body %q", resp.Status, string(rb))
+	}
+	return nil
+}
+
+// rewritePayloadRepo replaces every reference to the repository from in
+// the payload with the repository to. Both are in the form of org/repo.
+func rewritePayloadRepo(payload []byte, from, to string) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return nil, err
+	}
+
+	fromOrg, _ := splitRepo(from)
+	toOrg, toRepo := splitRepo(to)
+	r := strings.NewReplacer("gitee.com/"+from+"/", "gitee.com/"+to+"/", "gitee.com/"+from+".git", "gitee.com/"+to+".git")
+
+	var walk func(interface{}) interface{}
+	walk = func(i interface{}) interface{} {
+		switch t := i.(type) {
+		case map[string]interface{}:
+			for k, item := range t {
+				t[k] = walk(item)
+			}
+
+			if fn, ok := t["full_name"].(string); ok && fn == from {
+				t["full_name"] = to
+				t["path_with_namespace"] = to
+				t["path"] = toRepo
+				t["name"] = toRepo
+				if _, ok := t["namespace"].(string); ok {
+					t["namespace"] = toOrg
+				}
+				for _, k := range []string{"html_url", "url"} {
+					if s, ok := t[k].(string); ok && strings.HasSuffix(s, "gitee.com/"+from) {
+						t[k] = strings.TrimSuffix(s, from) + to
+					}
+				}
+				// The push events are dispatched by the owner of repository.
+				if owner, ok := t["owner"].(map[string]interface{}); ok {
+					rewriteOwner(owner, fromOrg, toOrg)
+				}
+			}
+			return t
+
+		case []interface{}:
+			for k, item := range t {
+				t[k] = walk(item)
+			}
+			return t
+
+		case string:
+			return r.Replace(t)
+		}
+		return i
+	}
+
+	return json.Marshal(walk(v))
+}
+
+// rewriteOwner replaces the org from with the org to in the owner of
+// repository.
+func rewriteOwner(owner map[string]interface{}, from, to string) {
+	for _, k := range []string{"login", "name", "username", "path"} {
+		if s, ok := owner[k].(string); ok && s == from {
+			owner[k] = to
+		}
+	}
+	for _, k := range []string{"html_url", "url"} {
+		if s, ok := owner[k].(string); ok && strings.HasSuffix(s, "gitee.com/"+from) {
+			owner[k] = strings.TrimSuffix(s, from) + to
+		}
+	}
+}
+
+func splitRepo(s string) (string, string) {
+	v := strings.Split(s, "/")
+	return v[0], v[1]
+}
+
+func splitList(s string) sets.String {
+	r := sets.NewString()
+	for _, item := range strings.Split(s, ",") {
+		if v := strings.TrimSpace(item); v != "" {
+			r.Insert(v)
+		}
+	}
+	return r
+}
@@ -0,0 +1,43 @@
+package externalplugin
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/opensourceways/yabot/prow/pluginhelp/externalplugins"
+)
+
+// Options holds the options of an external plugin server.
+type Options struct {
+	Port         int
+	PluginConfig string
+	GracePeriod  time.Duration
+
+	// HMACSecretFile is the path to the file containing the secret shared
+	// with the hook, which is set as hmac_secret_file of the external plugin
+	// in the plugin config of hook.
+	HMACSecretFile string
+	// MaxSkew is how old a signed event can be.
+	MaxSkew time.Duration
+}
+
+// AddFlags injects the options into the given FlagSet.
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.IntVar(&o.Port, "port", 8888, "Port to listen on.")
+	fs.StringVar(&o.PluginConfig, "plugin-config", "/etc/plugins/plugins.yaml", "Path to plugin config file.")
+	fs.DurationVar(&o.GracePeriod, "grace-period", 180*time.Second, "On shutdown, try to handle remaining events for the specified duration.")
+	fs.StringVar(&o.HMACSecretFile, "hmac-secret-file", "/etc/webhook/hmac", "Path to the file containing the secret shared with the hook.")
+	fs.DurationVar(&o.MaxSkew, "max-skew", externalplugins.DefaultMaxSkew, "How old a signed event can be.")
+}
+
+// Validate validates the options.
+func (o *Options) Validate() error {
+	if o.PluginConfig == "" {
+		return fmt.Errorf("--plugin-config must be set")
+	}
+	if o.HMACSecretFile == "" {
+		return fmt.Errorf("--hmac-secret-file must be set")
+	}
+	return nil
+}
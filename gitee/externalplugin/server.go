@@ -0,0 +1,234 @@
+// Package externalplugin provides the framework to run a plugin implementing
+// plugins.Plugin as an external plugin of the gitee hook. It authenticates the
+// events sent by the hook, demuxes them to the handlers registered by the plugin
+// and serves the "/help" endpoint, so that a plugin can be moved between being
+// in-process and external with minimal changes.
+package externalplugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/interrupts"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+	"github.com/opensourceways/yabot/prow/pluginhelp/externalplugins"
+)
+
+var _ plugins.Plugins = (*Server)(nil)
+
+// Server serves an external plugin. It implements plugins.Plugins, by which
+// the plugin registers its handlers as it does in the hook.
+type Server struct {
+	o      Options
+	agent  *plugins.ConfigAgent
+	secret []byte
+
+	pluginHelp          map[string]plugins.HelpProvider
+	issueHandlers       map[string]plugins.IssueHandler
+	pullRequestHandlers map[string]plugins.PullRequestHandler
+	pushEventHandlers   map[string]plugins.PushEventHandler
+	noteEventHandlers   map[string]plugins.NoteEventHandler
+
+	// Tracks running handlers for graceful shutdown
+	wg sync.WaitGroup
+}
+
+// NewServer returns a Server with the options.
+func NewServer(o Options) (*Server, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	secret, err := ioutil.ReadFile(o.HMACSecretFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		o:                   o,
+		agent:               plugins.NewConfigAgent(),
+		secret:              bytes.TrimSpace(secret),
+		pluginHelp:          map[string]plugins.HelpProvider{},
+		issueHandlers:       map[string]plugins.IssueHandler{},
+		pullRequestHandlers: map[string]plugins.PullRequestHandler{},
+		pushEventHandlers:   map[string]plugins.PushEventHandler{},
+		noteEventHandlers:   map[string]plugins.NoteEventHandler{},
+	}, nil
+}
+
+// GetPluginConfig returns the configuration of plugin. It can be passed to
+// the constructor of plugin as plugins.GetPluginConfig.
+func (s *Server) GetPluginConfig(name string) plugins.PluginConfig {
+	c := s.agent.Config()
+	if c == nil {
+		return nil
+	}
+	return c.GetPluginConfig(name)
+}
+
+// GetRepoPluginConfig returns the configuration of plugin for the repo. The
+// configuration in repo is not applied to the external plugin. It can be
+// passed to the constructor of plugin as plugins.GetRepoPluginConfig.
+func (s *Server) GetRepoPluginConfig(name, _, _ string) plugins.PluginConfig {
+	return s.GetPluginConfig(name)
+}
+
+// Run loads the configuration of plugin, registers its handlers and serves
+// the events until it is interrupted.
+func (s *Server) Run(p plugins.Plugin) {
+	defer interrupts.WaitForGracefulShutdown()
+
+	name := p.PluginName()
+	s.agent.RegisterPluginConfigBuilder(name, p.NewPluginConfig)
+	if err := s.agent.Start(s.o.PluginConfig, false, nil); err != nil {
+		logrus.WithError(err).Fatal("Error loading plugin config.")
+	}
+
+	p.RegisterEventHandler(s)
+	s.RegisterHelper(name, p.HelpProvider)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", s)
+	externalplugins.ServeExternalPluginHelp(mux, logrus.WithField("plugin", name), externalplugins.ExternalPluginHelpProvider(s.pluginHelp[name]))
+
+	interrupts.OnInterrupt(func() {
+		s.wg.Wait()
+	})
+
+	httpServer := &http.Server{Addr: ":" + strconv.Itoa(s.o.Port), Handler: mux}
+	interrupts.ListenAndServe(httpServer, s.o.GracePeriod)
+}
+
+// RegisterHelper registers a plugin's helper method.
+func (s *Server) RegisterHelper(name string, fn plugins.HelpProvider) {
+	s.pluginHelp[name] = fn
+}
+
+// RegisterIssueHandler registers a plugin's gitee.IssueEvent handler.
+func (s *Server) RegisterIssueHandler(name string, fn plugins.IssueHandler) {
+	s.issueHandlers[name] = fn
+}
+
+// RegisterPullRequestHandler registers a plugin's gitee.PullRequestEvent handler.
+func (s *Server) RegisterPullRequestHandler(name string, fn plugins.PullRequestHandler) {
+	s.pullRequestHandlers[name] = fn
+}
+
+// RegisterPushEventHandler registers a plugin's gitee.PushEvent handler.
+func (s *Server) RegisterPushEventHandler(name string, fn plugins.PushEventHandler) {
+	s.pushEventHandlers[name] = fn
+}
+
+// RegisterNoteEventHandler registers a plugin's gitee.NoteEvent handler.
+func (s *Server) RegisterNoteEventHandler(name string, fn plugins.NoteEventHandler) {
+	s.noteEventHandlers[name] = fn
+}
+
+func (s *Server) HelpProviders() map[string]plugins.HelpProvider {
+	return s.pluginHelp
+}
+
+// ServeHTTP validates an event sent by the hook and handles it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "405 Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := externalplugins.ValidateRequest(r, s.secret, s.o.MaxSkew)
+	if err != nil {
+		logrus.WithError(err).Warn("Invalid event.")
+		http.Error(w, "403 Forbidden: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
+	eventType := r.Header.Get("X-Gitee-Event")
+	if eventType == "" {
+		http.Error(w, "400 Bad Request: Missing X-Gitee-Event Header", http.StatusBadRequest)
+		return
+	}
+	eventGUID := r.Header.Get("X-Gitee-Timestamp")
+
+	if err := s.demuxEvent(eventType, eventGUID, payload); err != nil {
+		logrus.WithError(err).Error("Error parsing event.")
+		http.Error(w, "400 Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	fmt.Fprint(w, "Event received. Have a nice day.")
+}
+
+func (s *Server) demuxEvent(eventType, eventGUID string, payload []byte) error {
+	l := logrus.WithFields(
+		logrus.Fields{
+			"event-type":     eventType,
+			github.EventGUID: eventGUID,
+		},
+	)
+
+	switch eventType {
+	case "Note Hook":
+		var e sdk.NoteEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return err
+		}
+		for name, h := range s.noteEventHandlers {
+			h := h
+			s.handle(l, name, func() error { return h(&e, l) })
+		}
+
+	case "Issue Hook":
+		var e sdk.IssueEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return err
+		}
+		for name, h := range s.issueHandlers {
+			h := h
+			s.handle(l, name, func() error { return h(&e, l) })
+		}
+
+	case "Merge Request Hook":
+		var e sdk.PullRequestEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return err
+		}
+		for name, h := range s.pullRequestHandlers {
+			h := h
+			s.handle(l, name, func() error { return h(&e, l) })
+		}
+
+	case "Push Hook":
+		var e sdk.PushEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return err
+		}
+		for name, h := range s.pushEventHandlers {
+			h := h
+			s.handle(l, name, func() error { return h(&e, l) })
+		}
+
+	default:
+		l.Debug("Ignoring unhandled event type")
+	}
+	return nil
+}
+
+func (s *Server) handle(l *logrus.Entry, name string, fn func() error) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		if err := fn(); err != nil {
+			l.WithField("plugin", name).WithError(err).Error("Error handling event.")
+		}
+	}()
+}
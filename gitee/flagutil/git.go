@@ -0,0 +1,71 @@
+package flagutil
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/git/v2"
+)
+
+// NewPushableClientFactory wraps the git client factory so that the repo
+// clients it returns push through their own executor to the remote
+// resolved by publishRemote. The factory of test-infra never sets the
+// logger of publisher, so its ForcePush always panics.
+func NewPushableClientFactory(f git.ClientFactory, publishRemote func(org, repo string) (string, error), censor git.Censor) git.ClientFactory {
+	return &pushableClientFactory{
+		ClientFactory: f,
+		publishRemote: publishRemote,
+		censor:        censor,
+	}
+}
+
+type pushableClientFactory struct {
+	git.ClientFactory
+
+	publishRemote func(org, repo string) (string, error)
+	censor        git.Censor
+}
+
+func (f *pushableClientFactory) ClientFor(org, repo string) (git.RepoClient, error) {
+	r, err := f.ClientFactory.ClientFor(org, repo)
+	if err != nil {
+		return nil, err
+	}
+	return &pushableRepoClient{RepoClient: r, factory: f, org: org, repo: repo}, nil
+}
+
+func (f *pushableClientFactory) ClientFromDir(org, repo, dir string) (git.RepoClient, error) {
+	r, err := f.ClientFactory.ClientFromDir(org, repo, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &pushableRepoClient{RepoClient: r, factory: f, org: org, repo: repo}, nil
+}
+
+type pushableRepoClient struct {
+	git.RepoClient
+
+	factory *pushableClientFactory
+	org     string
+	repo    string
+}
+
+// ForcePush pushes the local state to the remote.
+func (r *pushableRepoClient) ForcePush(branch string) error {
+	logger := logrus.WithFields(logrus.Fields{"org": r.org, "repo": r.repo})
+	logger.Infof("Pushing branch %q", branch)
+
+	remote, err := r.factory.publishRemote(r.org, r.repo)
+	if err != nil {
+		return err
+	}
+
+	e, err := git.NewCensoringExecutor(r.Directory(), r.factory.censor, logger)
+	if err != nil {
+		return err
+	}
+	if out, err := e.Run("push", "--force", remote, branch); err != nil {
+		return fmt.Errorf("error pushing %q: %v %v", branch, err, string(out))
+	}
+	return nil
+}
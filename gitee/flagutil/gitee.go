@@ -3,6 +3,7 @@ package flagutil
 import (
 	"flag"
 	"fmt"
+	"net/url"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/test-infra/prow/config/secret"
@@ -88,7 +89,26 @@ func (o *GiteeOptions) GitClient(secretAgent *secret.Agent, dryRun bool) (git.Cl
 		opts.GitUser = userInfo
 		opts.Censor = secretAgent.Censor
 	}
-	return git.NewClientFactory(setOpt)
+	gc, err := git.NewClientFactory(setOpt)
+	if err != nil {
+		return nil, err
+	}
+
+	// The changes are pushed to the fork of bot.
+	publishRemote := func(_, repo string) (string, error) {
+		login, err := c.BotName()
+		if err != nil {
+			return "", err
+		}
+		u := &url.URL{
+			Scheme: "https",
+			Host:   "gitee.com",
+			User:   url.UserPassword(login, string(f())),
+			Path:   fmt.Sprintf("%s/%s", login, repo),
+		}
+		return u.String(), nil
+	}
+	return NewPushableClientFactory(gc, publishRemote, secretAgent.Censor), nil
 }
 
 func token(tokenPath string, secretAgent *secret.Agent) (func() []byte, error) {
@@ -3,6 +3,8 @@ package gitee
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -195,7 +197,17 @@ func (c *client) GetPullRequestChanges(org, repo string, number int) ([]github.P
 	var r []github.PullRequestChange
 
 	for _, f := range fs {
-		r = append(r, github.PullRequestChange{Filename: f.Filename})
+		// The line counts are only used to weigh the files, so they are
+		// zero if gitee returns the invalid ones.
+		additions, _ := strconv.Atoi(f.Additions)
+		deletions, _ := strconv.Atoi(f.Deletions)
+
+		r = append(r, github.PullRequestChange{
+			Filename:  f.Filename,
+			Additions: additions,
+			Deletions: deletions,
+			Changes:   additions + deletions,
+		})
 	}
 	return r, nil
 }
@@ -386,6 +398,18 @@ func (c *client) GetGiteeRepo(org, repo string) (sdk.Project, error) {
 	return v, formatErr(err, "get repo")
 }
 
+// GetPathContent returns the file at path on ref. The returned content is
+// empty if the file doesn't exist.
+func (c *client) GetPathContent(org, repo, path, ref string) (sdk.Content, error) {
+	opt := sdk.GetV5ReposOwnerRepoContentsPathOpts{Ref: optional.NewString(ref)}
+	v, resp, err := c.ac.RepositoriesApi.GetV5ReposOwnerRepoContentsPath(
+		context.Background(), org, repo, path, &opt)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		return sdk.Content{}, nil
+	}
+	return v, formatErr(err, "get path content")
+}
+
 func (c *client) MergePR(owner, repo string, number int, opt sdk.PullRequestMergePutParam) error {
 	_, err := c.ac.PullRequestsApi.PutV5ReposOwnerRepoPullsNumberMerge(
 		context.Background(), owner, repo, int32(number), opt)
@@ -413,6 +437,11 @@ func (c *client) GetRepos(org string) ([]sdk.Project, error) {
 	return r, nil
 }
 
+func (c *client) GetRepoLabels(owner, repo string) ([]sdk.Label, error) {
+	labels, _, err := c.ac.LabelsApi.GetV5ReposOwnerRepoLabels(context.Background(), owner, repo, nil)
+	return labels, formatErr(err, "list repo labels")
+}
+
 func (c *client) AddIssueLabel(org, repo, number, label string) error {
 	opt := &sdk.PostV5ReposOwnerRepoIssuesNumberLabelsOpts{Body: optional.NewInterface([]string{label})}
 	_, _, err := c.ac.LabelsApi.PostV5ReposOwnerRepoIssuesNumberLabels(context.Background(), org, repo, number, opt)
@@ -36,9 +36,11 @@ type Client interface {
 	GetGiteePullRequest(org, repo string, number int) (sdk.PullRequest, error)
 	GetSingleCommit(org, repo, SHA string) (github.SingleCommit, error)
 	GetGiteeRepo(org, repo string) (sdk.Project, error)
+	GetPathContent(org, repo, path, ref string) (sdk.Content, error)
 	MergePR(owner, repo string, number int, opt sdk.PullRequestMergePutParam) error
 
 	GetRepos(org string) ([]sdk.Project, error)
+	GetRepoLabels(owner, repo string) ([]sdk.Label, error)
 	RemoveIssueLabel(org, repo, number, label string) error
 	AddIssueLabel(org, repo, number, label string) error
 }
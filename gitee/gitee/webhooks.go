@@ -75,6 +75,12 @@ func ValidateWebhook(w http.ResponseWriter, r *http.Request, tokenGenerator func
 	return eventType, eventGUID, payload, true, http.StatusOK
 }
 
+// PayloadSignature returns the value of X-Gitee-Token header which Gitee
+// would send along with an event delivered at timestamp.
+func PayloadSignature(timestamp string, key []byte) string {
+	return payloadSignature(timestamp, string(key))
+}
+
 func payloadSignature(timestamp, key string) string {
 	mac := hmac.New(sha256.New, []byte(key))
 
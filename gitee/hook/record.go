@@ -0,0 +1,147 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// headerGiteeToken is the header carrying the webhook signature. It is never
+// recorded because the replay tool re-signs every event it sends.
+const headerGiteeToken = "X-Gitee-Token"
+
+// RecordedEvent is a validated webhook event persisted by the recorder.
+type RecordedEvent struct {
+	EventType  string          `json:"event_type"`
+	EventGUID  string          `json:"event_guid"`
+	Repo       string          `json:"repo,omitempty"`
+	RecordedAt time.Time       `json:"recorded_at"`
+	Header     http.Header     `json:"header"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// NewRecordingDispatcher returns a Dispatcher which stores every event under
+// dir, grouped by event type, before handing it over to d.
+func NewRecordingDispatcher(dir string, d Dispatcher) Dispatcher {
+	return &recordingDispatcher{dir: dir, Dispatcher: d}
+}
+
+type recordingDispatcher struct {
+	Dispatcher
+
+	dir string
+}
+
+func (r *recordingDispatcher) Dispatch(eventType, eventGUID string, payload []byte, h http.Header) error {
+	if err := r.record(eventType, eventGUID, payload, h); err != nil {
+		logrus.WithField("event-type", eventType).WithError(err).Error("Failed to record event.")
+	}
+
+	return r.Dispatcher.Dispatch(eventType, eventGUID, payload, h)
+}
+
+func (r *recordingDispatcher) record(eventType, eventGUID string, payload []byte, h http.Header) error {
+	header := h.Clone()
+	header.Del(headerGiteeToken)
+
+	e := RecordedEvent{
+		EventType:  eventType,
+		EventGUID:  eventGUID,
+		Repo:       RepoOfPayload(payload),
+		RecordedAt: time.Now(),
+		Header:     header,
+		Payload:    json.RawMessage(payload),
+	}
+
+	b, err := json.MarshalIndent(&e, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(r.dir, FileNameSafe(eventTypeDir(eventType)))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.json", e.RecordedAt.UnixNano(), FileNameSafe(eventGUID))
+	return ioutil.WriteFile(filepath.Join(dir, name), b, 0644)
+}
+
+// LoadRecordedEvents reads all the events recorded under dir and returns them
+// ordered by the time they were recorded.
+func LoadRecordedEvents(dir string) ([]RecordedEvent, error) {
+	var r []RecordedEvent
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var e RecordedEvent
+		if err := json.Unmarshal(b, &e); err != nil {
+			return fmt.Errorf("parse %s: %v", path, err)
+		}
+		r = append(r, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(r, func(i, j int) bool {
+		return r[i].RecordedAt.Before(r[j].RecordedAt)
+	})
+	return r, nil
+}
+
+// RepoOfPayload returns the full name of the repository which the event
+// payload belongs to, or an empty string if it can't be found.
+func RepoOfPayload(payload []byte) string {
+	var v struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return ""
+	}
+	return v.Repository.FullName
+}
+
+// eventTypeDir converts the event type such as "Merge Request Hook" to
+// a directory name like "merge_request_hook".
+func eventTypeDir(eventType string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(eventType)), " ", "_")
+}
+
+// FileNameSafe converts s, which comes from the request, to a name which
+// can be used as a single element of path.
+func FileNameSafe(s string) string {
+	r := strings.Map(func(r rune) rune {
+		if r == '/' || r == os.PathSeparator || r == ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+
+	if r == "" || r == "." || r == ".." {
+		return strings.Repeat("_", len(r)+1)
+	}
+	return r
+}
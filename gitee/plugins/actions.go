@@ -0,0 +1,200 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// The types of action which an external plugin can ask the hook to execute.
+const (
+	ActionAddLabel    = "add_label"
+	ActionRemoveLabel = "remove_label"
+	ActionComment     = "comment"
+	ActionAssign      = "assign"
+	ActionUnassign    = "unassign"
+	ActionMerge       = "merge"
+)
+
+// Action is an operation on the pull request or issue of the event, which
+// an external plugin replies to the hook with. The external plugin replies
+// with a JSON list of actions, and the hook executes the ones allowed for it.
+type Action struct {
+	// Type is one of add_label, remove_label, comment, assign, unassign and merge.
+	Type string `json:"type"`
+	// Labels are the labels to add or remove.
+	Labels []string `json:"labels,omitempty"`
+	// Body is the content of comment.
+	Body string `json:"body,omitempty"`
+	// Users are the logins to assign or unassign. Only the first one is
+	// assigned for an issue, since Gitee allows one assignee at most.
+	Users []string `json:"users,omitempty"`
+	// MergeMethod is one of merge, squash and rebase. Defaults to merge.
+	MergeMethod string `json:"merge_method,omitempty"`
+}
+
+// actionTarget is the pull request or issue which the actions are executed on.
+type actionTarget struct {
+	org  string
+	repo string
+	// prNumber is set if the target is a pull request.
+	prNumber int
+	// issueNumber is set if the target is an issue.
+	issueNumber string
+}
+
+func (t *actionTarget) isPR() bool {
+	return t.prNumber > 0
+}
+
+// parseActions parses the actions in the response of external plugin. It returns
+// nothing if the response is not a JSON list, which keeps the compatibility with
+// the external plugins which don't reply with actions.
+func parseActions(resp []byte) ([]Action, error) {
+	resp = bytes.TrimSpace(resp)
+	if len(resp) == 0 || resp[0] != '[' {
+		return nil, nil
+	}
+
+	var actions []Action
+	if err := json.Unmarshal(resp, &actions); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// executeActions executes the actions replied by the external plugin on target.
+// The actions not allowed for the plugin are refused.
+func (d *dispatcher) executeActions(l *logrus.Entry, p ExternalPlugin, t *actionTarget, actions []Action) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	if t == nil {
+		return fmt.Errorf("the event has no pull request or issue to execute actions on")
+	}
+	if d.gc == nil {
+		return fmt.Errorf("no gitee client to execute actions")
+	}
+
+	allowed := sets.NewString(p.AllowedActions...)
+
+	var errs []error
+	for i := range actions {
+		a := &actions[i]
+
+		al := l.WithFields(logrus.Fields{
+			"audit":           true,
+			"external-plugin": p.Name,
+			"action":          a.Type,
+			"org":             t.org,
+			"repo":            t.repo,
+			"pr":              t.prNumber,
+			"issue":           t.issueNumber,
+			"labels":          a.Labels,
+			"users":           a.Users,
+		})
+
+		if !allowed.Has(a.Type) {
+			al.Warn("Refused the action not allowed for external plugin.")
+			errs = append(errs, fmt.Errorf("action %s is not allowed", a.Type))
+			continue
+		}
+
+		if err := d.executeAction(t, a); err != nil {
+			al.WithError(err).Error("Failed to execute the action of external plugin.")
+			errs = append(errs, err)
+			continue
+		}
+		al.Info("Executed the action of external plugin.")
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func (d *dispatcher) executeAction(t *actionTarget, a *Action) error {
+	org, repo := t.org, t.repo
+
+	switch a.Type {
+	case ActionAddLabel:
+		for _, label := range a.Labels {
+			var err error
+			if t.isPR() {
+				err = d.gc.AddPRLabel(org, repo, t.prNumber, label)
+			} else {
+				err = d.gc.AddIssueLabel(org, repo, t.issueNumber, label)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+	case ActionRemoveLabel:
+		for _, label := range a.Labels {
+			var err error
+			if t.isPR() {
+				err = d.gc.RemovePRLabel(org, repo, t.prNumber, label)
+			} else {
+				err = d.gc.RemoveIssueLabel(org, repo, t.issueNumber, label)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+	case ActionComment:
+		if a.Body == "" {
+			return fmt.Errorf("empty comment")
+		}
+		if t.isPR() {
+			return d.gc.CreatePRComment(org, repo, t.prNumber, a.Body)
+		}
+		return d.gc.CreateGiteeIssueComment(org, repo, t.issueNumber, a.Body)
+
+	case ActionAssign:
+		if len(a.Users) == 0 {
+			return fmt.Errorf("no users to assign")
+		}
+		if t.isPR() {
+			return d.gc.AssignPR(org, repo, t.prNumber, a.Users)
+		}
+		return d.gc.AssignGiteeIssue(org, repo, t.issueNumber, a.Users[0])
+
+	case ActionUnassign:
+		if len(a.Users) == 0 {
+			return fmt.Errorf("no users to unassign")
+		}
+		if t.isPR() {
+			return d.gc.UnassignPR(org, repo, t.prNumber, a.Users)
+		}
+		return d.gc.UnassignGiteeIssue(org, repo, t.issueNumber, a.Users[0])
+
+	case ActionMerge:
+		if !t.isPR() {
+			return fmt.Errorf("only pull request can be merged")
+		}
+		if d.mergeable != nil {
+			pr, err := d.gc.GetGiteePullRequest(org, repo, t.prNumber)
+			if err != nil {
+				return err
+			}
+			if reason := d.mergeable(org, repo, &pr); reason != "" {
+				return fmt.Errorf("refused to merge, since %s", reason)
+			}
+		}
+
+		m := a.MergeMethod
+		if m == "" {
+			m = "merge"
+		}
+		return d.gc.MergePR(org, repo, t.prNumber, gitee.PullRequestMergePutParam{MergeMethod: m})
+
+	default:
+		return fmt.Errorf("unknown action type: %s", a.Type)
+	}
+	return nil
+}
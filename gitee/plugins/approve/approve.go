@@ -0,0 +1,419 @@
+package approve
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/labels"
+	"k8s.io/test-infra/prow/pluginhelp"
+	"k8s.io/test-infra/prow/plugins/approve/approvers"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+const (
+	pluginName = "approve"
+
+	approveCommand  = "APPROVE"
+	cancelArgument  = "cancel"
+	lgtmCommand     = "LGTM"
+	noIssueArgument = "no-issue"
+
+	// giteeURL is the base of links to the files in notification.
+	giteeURL = "https://gitee.com"
+)
+
+var (
+	commandRegex      = regexp.MustCompile(`(?m)^/([^\s]+)[\t ]*([^\n\r]*)`)
+	notificationRegex = regexp.MustCompile(`(?is)^\[` + approvers.ApprovalNotificationName + `\] *?([^\n]*)(?:\n\n(.*))?`)
+)
+
+type approve struct {
+	getPluginConfig plugins.GetRepoPluginConfig
+	gc              giteeClient
+	oc              ownersClient
+}
+
+func NewApprove(f plugins.GetRepoPluginConfig, gc giteeClient, oc ownersClient) plugins.Plugin {
+	return &approve{
+		getPluginConfig: f,
+		gc:              gc,
+		oc:              oc,
+	}
+}
+
+func (this *approve) HelpProvider(_ []prowConfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	pluginHelp := &pluginhelp.PluginHelp{
+		Description: "The approve plugin implements a pull request approval process that manages the '" + labels.Approved + "' label and an approval notification comment. Approval is achieved when the set of users that have approved the PR is capable of approving every file changed by the PR. A user is able to approve a file if their username or an alias they belong to is listed in the 'approvers' section of an OWNERS file in the directory of the file or higher in the directory tree.",
+	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/approve [no-issue|cancel]",
+		Description: "Approves a pull request",
+		Featured:    true,
+		WhoCanUse:   "Users listed as 'approvers' in appropriate OWNERS files.",
+		Examples:    []string{"/approve", "/approve no-issue", "/approve cancel"},
+	})
+	return pluginHelp, nil
+}
+
+func (this *approve) PluginName() string {
+	return pluginName
+}
+
+func (this *approve) NewPluginConfig() plugins.PluginConfig {
+	return &configuration{}
+}
+
+func (this *approve) RegisterEventHandler(p plugins.Plugins) {
+	name := this.PluginName()
+	p.RegisterNoteEventHandler(name, this.handleNoteEvent)
+	p.RegisterPullRequestHandler(name, this.handlePullRequestEvent)
+}
+
+func (this *approve) handleNoteEvent(e *sdk.NoteEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handleNoteEvent")
+	}()
+
+	if *(e.Action) != "comment" || *(e.NoteableType) != "PullRequest" || e.PullRequest.State != "open" {
+		log.Debug("Event is not a creation of a comment on an open PR, skipping.")
+		return nil
+	}
+
+	org := e.Repository.Namespace
+	repo := e.Repository.Path
+
+	opts, err := this.orgRepoConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	botName, err := this.gc.BotName()
+	if err != nil {
+		return err
+	}
+
+	if !isApprovalCommand(botName, opts.LgtmActsAsApprove, &comment{Body: e.Comment.Body, Author: e.Comment.User.Login}) {
+		log.Debug("Comment does not constitute approval, skipping event.")
+		return nil
+	}
+
+	return this.handle(org, repo, int(e.PullRequest.Number), opts, log)
+}
+
+func (this *approve) handlePullRequestEvent(e *sdk.PullRequestEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handlePullRequest")
+	}()
+
+	if e.PullRequest.State != "open" {
+		log.Debug("Pull request state is not open, skipping...")
+		return nil
+	}
+
+	action := plugins.ConvertPullRequestAction(e)
+	if action != github.PullRequestActionOpened && action != github.PullRequestActionSynchronize {
+		log.Debug("Pull request event action cannot constitute approval, skipping...")
+		return nil
+	}
+
+	pr := e.PullRequest
+	org := pr.Base.Repo.Namespace
+	repo := pr.Base.Repo.Path
+
+	opts, err := this.orgRepoConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	return this.handle(org, repo, int(pr.Number), opts, log)
+}
+
+// handle computes the approvers of pull request from the approval commands
+// in its comments, then updates the notification and the approved label.
+func (this *approve) handle(org, repo string, number int, opts *pluginConfig, log *logrus.Entry) error {
+	fetchErr := func(context string, err error) error {
+		return fmt.Errorf("failed to get %s for %s/%s#%d: %v", context, org, repo, number, err)
+	}
+
+	pr, err := this.gc.GetGiteePullRequest(org, repo, number)
+	if err != nil {
+		return fetchErr("pull request", err)
+	}
+	branch := pr.Base.Ref
+	author := ""
+	if pr.User != nil {
+		author = pr.User.Login
+	}
+
+	changes, err := this.gc.GetPullRequestChanges(org, repo, number)
+	if err != nil {
+		return fetchErr("PR file changes", err)
+	}
+	var filenames []string
+	for _, change := range changes {
+		filenames = append(filenames, change.Filename)
+	}
+
+	prLabels, err := this.gc.GetPRLabels(org, repo, number)
+	if err != nil {
+		return fetchErr("PR labels", err)
+	}
+	hasApprovedLabel := false
+	for _, label := range prLabels {
+		if label.Name == labels.Approved {
+			hasApprovedLabel = true
+			break
+		}
+	}
+
+	botName, err := this.gc.BotName()
+	if err != nil {
+		return fetchErr("bot name", err)
+	}
+
+	prComments, err := this.gc.ListPRComments(org, repo, number)
+	if err != nil {
+		return fetchErr("PR comments", err)
+	}
+
+	ro, err := this.oc.LoadRepoOwners(org, repo, branch)
+	if err != nil {
+		return fetchErr("repo owners", err)
+	}
+
+	approversHandler := approvers.NewApprovers(
+		approvers.NewOwners(log, filenames, ro, int64(number)),
+	)
+	approversHandler.RequireIssue = opts.IssueRequired
+	if opts.IssueRequired {
+		// The issues of gitee are not numbered, so the requirement is
+		// met by any issue associated with the pull request.
+		issues, err := this.gc.ListPrIssues(org, repo, int32(number))
+		if err != nil {
+			log.WithError(err).Error("Failed to list the issues associated with pull request.")
+		}
+		approversHandler.RequireIssue = len(issues) == 0
+	}
+	// Gitee doesn't tell who added the label, so the label added by human
+	// is not regarded as an approval.
+	approversHandler.ManuallyApproved = func() bool { return false }
+
+	// Author implicitly approves their own PR if config allows it
+	if !opts.RequireSelfApproval {
+		approversHandler.AddAuthorSelfApprover(author, pr.HtmlUrl+"#", false)
+	} else {
+		// Treat the author as an assignee, and suggest them if possible
+		approversHandler.AddAssignees(author)
+	}
+
+	comments := commentsFromPRComments(prComments)
+	approveComments := filterComments(comments, func(c *comment) bool {
+		return isApprovalCommand(botName, opts.LgtmActsAsApprove, c)
+	})
+	addApprovers(&approversHandler, approveComments, author)
+
+	for _, user := range pr.Assignees {
+		approversHandler.AddAssignees(user.Login)
+	}
+
+	notifications := filterComments(comments, notificationMatcher(botName))
+	this.updateNotification(org, repo, branch, number, notifications, approversHandler, log)
+
+	if !approversHandler.IsApproved() {
+		if hasApprovedLabel {
+			if err := this.gc.RemovePRLabel(org, repo, number, labels.Approved); err != nil {
+				log.WithError(err).Errorf("Failed to remove %q label from %s/%s#%d.", labels.Approved, org, repo, number)
+			}
+		}
+	} else if !hasApprovedLabel {
+		if err := this.gc.AddPRLabel(org, repo, number, labels.Approved); err != nil {
+			log.WithError(err).Errorf("Failed to add %q label to %s/%s#%d.", labels.Approved, org, repo, number)
+		}
+	}
+	return nil
+}
+
+// updateNotification keeps a single notification on the pull request. The
+// latest one is updated in place and the others are deleted.
+func (this *approve) updateNotification(org, repo, branch string, number int, notifications []*comment, ap approvers.Approvers, log *logrus.Entry) {
+	linkURL, _ := url.Parse(giteeURL)
+	message := approvers.GetMessage(ap, linkURL, org, repo, branch)
+	if message == nil {
+		return
+	}
+
+	latest := getLast(notifications)
+	if latest != nil {
+		for _, c := range notifications[:len(notifications)-1] {
+			if err := this.gc.DeletePRComment(org, repo, c.ID); err != nil {
+				log.WithError(err).Errorf("Failed to delete comment from %s/%s#%d, ID: %d.", org, repo, number, c.ID)
+			}
+		}
+
+		if strings.Contains(latest.Body, *message) {
+			return
+		}
+
+		if err := this.gc.UpdatePRComment(org, repo, latest.ID, *message); err != nil {
+			log.WithError(err).Errorf("Failed to update comment on %s/%s#%d, ID: %d.", org, repo, number, latest.ID)
+		}
+		return
+	}
+
+	if err := this.gc.CreatePRComment(org, repo, number, *message); err != nil {
+		log.WithError(err).Errorf("Failed to create comment on %s/%s#%d: %q.", org, repo, number, *message)
+	}
+}
+
+func (this *approve) orgRepoConfig(org, repo string) (*pluginConfig, error) {
+	cfg, err := this.pluginConfig(org, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if pc := cfg.ApproveFor(org, repo); pc != nil {
+		return pc, nil
+	}
+	return &pluginConfig{}, nil
+}
+
+func (this *approve) pluginConfig(org, repo string) (*configuration, error) {
+	c := this.getPluginConfig(this.PluginName(), org, repo)
+	if c == nil {
+		return nil, fmt.Errorf("can't find the configuration")
+	}
+
+	c1, ok := c.(*configuration)
+	if !ok {
+		return nil, fmt.Errorf("can't convert to configuration")
+	}
+
+	return c1, nil
+}
+
+func isApprovalCommand(botName string, lgtmActsAsApprove bool, c *comment) bool {
+	if c.Author == botName {
+		return false
+	}
+
+	for _, match := range commandRegex.FindAllStringSubmatch(c.Body, -1) {
+		cmd := strings.ToUpper(match[1])
+		if (cmd == lgtmCommand && lgtmActsAsApprove) || cmd == approveCommand {
+			return true
+		}
+	}
+	return false
+}
+
+func notificationMatcher(botName string) func(*comment) bool {
+	return func(c *comment) bool {
+		return c.Author == botName && notificationRegex.MatchString(c.Body)
+	}
+}
+
+// addApprovers iterates through the list of comments on a PR
+// and identifies all of the people that have said /approve and adds
+// them to the Approvers.  The function uses the latest approve or cancel comment
+// to determine the Users intention.
+func addApprovers(approversHandler *approvers.Approvers, approveComments []*comment, author string) {
+	for _, c := range approveComments {
+		if c.Author == "" {
+			continue
+		}
+
+		for _, match := range commandRegex.FindAllStringSubmatch(c.Body, -1) {
+			name := strings.ToUpper(match[1])
+			if name != approveCommand && name != lgtmCommand {
+				continue
+			}
+			args := strings.ToLower(strings.TrimSpace(match[2]))
+			if strings.Contains(args, cancelArgument) {
+				approversHandler.RemoveApprover(c.Author)
+				continue
+			}
+
+			if c.Author == author {
+				approversHandler.AddAuthorSelfApprover(
+					c.Author,
+					c.HTMLURL,
+					args == noIssueArgument,
+				)
+			}
+
+			if name == approveCommand {
+				approversHandler.AddApprover(
+					c.Author,
+					c.HTMLURL,
+					args == noIssueArgument,
+				)
+			} else {
+				approversHandler.AddLGTMer(
+					c.Author,
+					c.HTMLURL,
+					args == noIssueArgument,
+				)
+			}
+		}
+	}
+}
+
+type comment struct {
+	Body      string
+	Author    string
+	CreatedAt time.Time
+	HTMLURL   string
+	ID        int
+}
+
+// commentsFromPRComments converts the comments and sorts them in order of creation.
+func commentsFromPRComments(cs []sdk.PullRequestComments) []*comment {
+	comments := make([]*comment, 0, len(cs))
+	for i := range cs {
+		item := &cs[i]
+
+		c := &comment{
+			Body:    item.Body,
+			HTMLURL: item.HtmlUrl,
+			ID:      int(item.Id),
+		}
+		if item.User != nil {
+			c.Author = item.User.Login
+		}
+		c.CreatedAt, _ = time.Parse(time.RFC3339, item.CreatedAt)
+
+		comments = append(comments, c)
+	}
+
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+	})
+	return comments
+}
+
+func filterComments(comments []*comment, filter func(*comment) bool) []*comment {
+	filtered := make([]*comment, 0, len(comments))
+	for _, c := range comments {
+		if filter(c) {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+func getLast(cs []*comment) *comment {
+	if len(cs) == 0 {
+		return nil
+	}
+	return cs[len(cs)-1]
+}
@@ -0,0 +1,25 @@
+package approve
+
+import (
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/repoowners"
+)
+
+type giteeClient interface {
+	BotName() (string, error)
+	GetGiteePullRequest(org, repo string, number int) (sdk.PullRequest, error)
+	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+	GetPRLabels(org, repo string, number int) ([]sdk.Label, error)
+	ListPRComments(org, repo string, number int) ([]sdk.PullRequestComments, error)
+	ListPrIssues(org, repo string, number int32) ([]sdk.Issue, error)
+	CreatePRComment(owner, repo string, number int, comment string) error
+	UpdatePRComment(org, repo string, commentID int, comment string) error
+	DeletePRComment(org, repo string, ID int) error
+	AddPRLabel(owner, repo string, number int, label string) error
+	RemovePRLabel(owner, repo string, number int, label string) error
+}
+
+type ownersClient interface {
+	LoadRepoOwners(org, repo, base string) (repoowners.RepoOwner, error)
+}
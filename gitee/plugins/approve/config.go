@@ -0,0 +1,49 @@
+package approve
+
+import (
+	"github.com/huaweicloud/golangsdk"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+type configuration struct {
+	Approve []pluginConfig `json:"approve,omitempty"`
+}
+
+func (c *configuration) Validate() error {
+	_, err := golangsdk.BuildRequestBody(c, "")
+	return err
+}
+
+func (c *configuration) SetDefault() {
+}
+
+// ApproveFor returns the config for the repo. The config of repo takes
+// precedence over the one of its org. It is nil if neither is set.
+func (c *configuration) ApproveFor(org, repo string) *pluginConfig {
+	i := plugins.FindConfig(org, repo, len(c.Approve), func(i int) []string {
+		return c.Approve[i].Repos
+	})
+	if i < 0 {
+		return nil
+	}
+	return &(c.Approve[i])
+}
+
+type pluginConfig struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos" required:"true"`
+
+	// IssueRequired indicates if an associated issue is required for approval
+	// in the specified repos. The issue is associated on gitee when the pull
+	// request is created or edited.
+	IssueRequired bool `json:"issue_required,omitempty"`
+
+	// RequireSelfApproval requires PR authors to explicitly approve their PRs.
+	// Otherwise the plugin assumes the author of the PR approves the changes in the PR.
+	RequireSelfApproval bool `json:"require_self_approval,omitempty"`
+
+	// LgtmActsAsApprove indicates that the lgtm command should be used to
+	// indicate approval
+	LgtmActsAsApprove bool `json:"lgtm_acts_as_approve,omitempty"`
+}
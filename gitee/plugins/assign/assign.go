@@ -0,0 +1,267 @@
+package assign
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+	originp "github.com/opensourceways/yabot/prow/plugins"
+)
+
+const pluginName = "assign"
+
+var (
+	assignRe = regexp.MustCompile(`(?mi)^/(un)?assign(( +@?[-\w]+?)*)\s*$`)
+	ccRe     = regexp.MustCompile(`(?mi)^/(un)?cc(( +@?[-\w]+?)*)\s*$`)
+)
+
+type assign struct {
+	gc giteeClient
+}
+
+// NewAssign returns the assign plugin. Gitee doesn't request the reviews of
+// pull request apart from assigning, so both /assign and /cc manage the
+// assignees of pull request.
+func NewAssign(gc giteeClient) plugins.Plugin {
+	return &assign{gc: gc}
+}
+
+func (this *assign) HelpProvider(_ []prowConfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	pluginHelp := &pluginhelp.PluginHelp{
+		Description: "The assign plugin assigns or requests reviews from users. Specific users can be assigned with the command '/assign @user1' or have reviews requested of them with the command '/cc @user1'. If no user is specified the commands default to targeting the user who created the command. Assignments and requested reviews can be removed in the same way that they are added by prefixing the commands with 'un'. The users must be collaborators of the repository, and an issue can only have one assignee.",
+	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/[un]assign [[@]<username>...]",
+		Description: "Assigns assignee(s) to the pull request or issue.",
+		Featured:    true,
+		WhoCanUse:   "Anyone can use the command, but the target user(s) must be collaborators of the repository.",
+		Examples:    []string{"/assign", "/unassign", "/assign @spongebob"},
+	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/[un]cc [[@]<username>...]",
+		Description: "Requests a review from the user(s) on the pull request.",
+		Featured:    true,
+		WhoCanUse:   "Anyone can use the command, but the target user(s) must be collaborators of the repository.",
+		Examples:    []string{"/cc", "/uncc", "/cc @spongebob"},
+	})
+	return pluginHelp, nil
+}
+
+func (this *assign) PluginName() string {
+	return pluginName
+}
+
+func (this *assign) NewPluginConfig() plugins.PluginConfig {
+	return nil
+}
+
+func (this *assign) RegisterEventHandler(p plugins.Plugins) {
+	p.RegisterNoteEventHandler(this.PluginName(), this.handleNoteEvent)
+}
+
+func (this *assign) handleNoteEvent(e *sdk.NoteEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handleNoteEvent")
+	}()
+
+	if *(e.Action) != "comment" {
+		log.Debug("Event is not a creation of a comment, skipping.")
+		return nil
+	}
+
+	org := e.Repository.Namespace
+	repo := e.Repository.Path
+	commenter := e.Comment.User.Login
+	body := e.Comment.Body
+
+	var resp []string
+	var err error
+	switch *(e.NoteableType) {
+	case "PullRequest":
+		toAdd, toRemove := parseCommands(assignRe, body, commenter)
+		ccAdd, ccRemove := parseCommands(ccRe, body, commenter)
+		toAdd.Insert(ccAdd.UnsortedList()...)
+		toRemove.Insert(ccRemove.UnsortedList()...)
+
+		resp, err = this.handlePR(org, repo, e.PullRequest, toAdd, toRemove, log)
+
+	case "Issue":
+		toAdd, toRemove := parseCommands(assignRe, body, commenter)
+
+		resp, err = this.handleIssue(org, repo, e.Issue, toAdd, toRemove, log)
+
+	default:
+		return nil
+	}
+
+	if len(resp) == 0 {
+		return err
+	}
+
+	msg := originp.FormatResponseRaw(body, e.Comment.HtmlUrl, commenter, strings.Join(resp, "\n\n"))
+	if *(e.NoteableType) == "PullRequest" {
+		err1 := this.gc.CreatePRComment(org, repo, int(e.PullRequest.Number), msg)
+		return utilerrors.NewAggregate([]error{err, err1})
+	}
+	err1 := this.gc.CreateGiteeIssueComment(org, repo, e.Issue.Number, msg)
+	return utilerrors.NewAggregate([]error{err, err1})
+}
+
+// handlePR updates the assignees of pull request and returns the responses
+// about the users who can't be assigned.
+func (this *assign) handlePR(org, repo string, pr *sdk.PullRequestHook, toAdd, toRemove sets.String, log *logrus.Entry) ([]string, error) {
+	current := sets.NewString()
+	for _, u := range pr.Assignees {
+		current.Insert(github.NormLogin(u.Login))
+	}
+
+	number := int(pr.Number)
+	var resp []string
+	var errs []error
+
+	if v := toRemove.Intersection(current); v.Len() > 0 {
+		if err := this.gc.UnassignPR(org, repo, number, v.List()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	valid, invalid, err := this.collaborators(org, repo, toAdd.Difference(current))
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(valid) > 0 {
+		log.Infof("Assigning %v to the pull request.", valid)
+		if err := this.gc.AssignPR(org, repo, number, valid); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(invalid) > 0 {
+		resp = append(resp, notCollaborators(invalid))
+	}
+
+	return resp, utilerrors.NewAggregate(errs)
+}
+
+// handleIssue updates the assignee of issue and returns the responses about
+// the users who can't be assigned. Gitee issue can only have one assignee,
+// so only the first valid user is assigned and replaces the current one.
+func (this *assign) handleIssue(org, repo string, issue *sdk.IssueHook, toAdd, toRemove sets.String, log *logrus.Entry) ([]string, error) {
+	current := ""
+	if issue.Assignee != nil {
+		current = github.NormLogin(issue.Assignee.Login)
+	}
+
+	number := issue.Number
+
+	if toAdd.Len() == 0 {
+		if current == "" || !toRemove.Has(current) {
+			return nil, nil
+		}
+
+		log.Infof("Unassigning %s from the issue.", current)
+		return nil, this.gc.UnassignGiteeIssue(org, repo, number, current)
+	}
+
+	valid, invalid, err := this.collaborators(org, repo, toAdd)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp []string
+	if len(invalid) > 0 {
+		resp = append(resp, notCollaborators(invalid))
+	}
+	if len(valid) == 0 {
+		return resp, nil
+	}
+	if len(valid) > 1 {
+		resp = append(resp, fmt.Sprintf("Gitee issue can only have one assignee, so %s is assigned and %s are not.", valid[0], strings.Join(valid[1:], ", ")))
+	}
+
+	if valid[0] == current {
+		return resp, nil
+	}
+
+	log.Infof("Assigning %s to the issue.", valid[0])
+	return resp, this.gc.AssignGiteeIssue(org, repo, number, valid[0])
+}
+
+// collaborators splits users into the collaborators of repo and the others.
+func (this *assign) collaborators(org, repo string, users sets.String) ([]string, []string, error) {
+	if users.Len() == 0 {
+		return nil, nil, nil
+	}
+
+	var all sets.String
+	var valid, invalid []string
+	for _, u := range users.List() {
+		b, err := this.gc.IsCollaborator(org, repo, u)
+		if err != nil {
+			// Gitee fails to check the collaborator directly sometimes, so
+			// it falls back to list the collaborators.
+			if all == nil {
+				cs, err1 := this.gc.ListCollaborators(org, repo)
+				if err1 != nil {
+					return nil, nil, err
+				}
+
+				all = sets.NewString()
+				for _, c := range cs {
+					all.Insert(github.NormLogin(c.Login))
+				}
+			}
+			b = all.Has(u)
+		}
+
+		if b {
+			valid = append(valid, u)
+		} else {
+			invalid = append(invalid, u)
+		}
+	}
+	return valid, invalid, nil
+}
+
+// parseCommands returns the users to be added and removed by the commands.
+// The commands without users target the commenter.
+func parseCommands(re *regexp.Regexp, body, commenter string) (sets.String, sets.String) {
+	toAdd := sets.NewString()
+	toRemove := sets.NewString()
+
+	for _, m := range re.FindAllStringSubmatch(body, -1) {
+		users := parseLogins(m[2])
+		if len(users) == 0 {
+			users = []string{github.NormLogin(commenter)}
+		}
+
+		if m[1] == "" {
+			toAdd.Insert(users...)
+		} else {
+			toRemove.Insert(users...)
+		}
+	}
+	return toAdd, toRemove
+}
+
+func parseLogins(text string) []string {
+	var r []string
+	for _, s := range strings.Fields(text) {
+		r = append(r, github.NormLogin(s))
+	}
+	return r
+}
+
+func notCollaborators(users []string) string {
+	return fmt.Sprintf("These users can't be assigned, since they are not collaborators of the repository: %s.", strings.Join(users, ", "))
+}
@@ -0,0 +1,16 @@
+package assign
+
+import (
+	"k8s.io/test-infra/prow/github"
+)
+
+type giteeClient interface {
+	IsCollaborator(owner, repo, login string) (bool, error)
+	ListCollaborators(org, repo string) ([]github.User, error)
+	AssignPR(owner, repo string, number int, logins []string) error
+	UnassignPR(owner, repo string, number int, logins []string) error
+	CreatePRComment(owner, repo string, number int, comment string) error
+	AssignGiteeIssue(org, repo string, number string, login string) error
+	UnassignGiteeIssue(org, repo string, number string, login string) error
+	CreateGiteeIssueComment(org, repo string, number string, comment string) error
+}
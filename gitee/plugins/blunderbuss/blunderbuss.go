@@ -0,0 +1,251 @@
+package blunderbuss
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+const pluginName = "blunderbuss"
+
+var autoCCRe = regexp.MustCompile(`(?mi)^/auto-cc\s*$`)
+
+type blunderbuss struct {
+	getPluginConfig plugins.GetRepoPluginConfig
+	gc              giteeClient
+	oc              ownersClient
+}
+
+// NewBlunderbuss returns the blunderbuss plugin which requests the reviewers
+// of pull request from the OWNERS files.
+func NewBlunderbuss(f plugins.GetRepoPluginConfig, gc giteeClient, oc ownersClient) plugins.Plugin {
+	return &blunderbuss{
+		getPluginConfig: f,
+		gc:              gc,
+		oc:              oc,
+	}
+}
+
+func (this *blunderbuss) HelpProvider(_ []prowConfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	pluginHelp := &pluginhelp.PluginHelp{
+		Description: "The blunderbuss plugin automatically requests reviews from reviewers when a new PR is created. The reviewers are selected based on the reviewers specified in the OWNERS files that apply to the files modified by the PR, and weighted by the lines changed. The fallback reviewers are requested if no OWNERS file applies.",
+	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/auto-cc",
+		Description: "Manually request reviews from reviewers for a PR. Useful if OWNERS file were updated since the PR was opened.",
+		Featured:    false,
+		WhoCanUse:   "Anyone",
+		Examples:    []string{"/auto-cc"},
+	})
+	return pluginHelp, nil
+}
+
+func (this *blunderbuss) PluginName() string {
+	return pluginName
+}
+
+func (this *blunderbuss) NewPluginConfig() plugins.PluginConfig {
+	return &configuration{}
+}
+
+func (this *blunderbuss) RegisterEventHandler(p plugins.Plugins) {
+	name := this.PluginName()
+	p.RegisterNoteEventHandler(name, this.handleNoteEvent)
+	p.RegisterPullRequestHandler(name, this.handlePullRequestEvent)
+}
+
+func (this *blunderbuss) handlePullRequestEvent(e *sdk.PullRequestEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handlePullRequest")
+	}()
+
+	if e.PullRequest.State != "open" {
+		log.Debug("Pull request state is not open, skipping...")
+		return nil
+	}
+
+	if plugins.ConvertPullRequestAction(e) != github.PullRequestActionOpened {
+		return nil
+	}
+
+	pr := e.PullRequest
+	org := pr.Base.Repo.Namespace
+	repo := pr.Base.Repo.Path
+
+	cfg, err := this.orgRepoConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	// The reviewers assigned by the author are kept and counted.
+	n := cfg.ReviewerCount - len(pr.Assignees)
+	if n <= 0 {
+		return nil
+	}
+	return this.handle(org, repo, pr, n, cfg, log)
+}
+
+func (this *blunderbuss) handleNoteEvent(e *sdk.NoteEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handleNoteEvent")
+	}()
+
+	if *(e.Action) != "comment" {
+		log.Debug("Event is not a creation of a comment, skipping.")
+		return nil
+	}
+
+	if *(e.NoteableType) != "PullRequest" {
+		return nil
+	}
+
+	if e.PullRequest.State != "open" || !autoCCRe.MatchString(e.Comment.Body) {
+		return nil
+	}
+
+	org := e.Repository.Namespace
+	repo := e.Repository.Path
+
+	cfg, err := this.orgRepoConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	return this.handle(org, repo, e.PullRequest, cfg.ReviewerCount, cfg, log)
+}
+
+// handle requests count reviewers of the pull request, except its author
+// and current assignees.
+func (this *blunderbuss) handle(org, repo string, pr *sdk.PullRequestHook, count int, cfg *pluginConfig, log *logrus.Entry) error {
+	number := int(pr.Number)
+
+	excluded := sets.NewString()
+	if pr.User != nil {
+		excluded.Insert(github.NormLogin(pr.User.Login))
+	}
+	for _, u := range pr.Assignees {
+		excluded.Insert(github.NormLogin(u.Login))
+	}
+
+	changes, err := this.gc.GetPullRequestChanges(org, repo, number)
+	if err != nil {
+		return err
+	}
+
+	ro, err := this.oc.LoadRepoOwners(org, repo, pr.Base.Ref)
+	if err != nil {
+		return err
+	}
+
+	candidates := potentialReviewers(ro, changes, excluded)
+	if len(candidates) == 0 {
+		log.Info("No OWNERS file applies, requesting the fallback reviewers.")
+
+		for _, u := range cfg.FallbackReviewers {
+			if u := github.NormLogin(u); !excluded.Has(u) {
+				candidates[u] = 1
+			}
+		}
+	}
+
+	reviewers := selectReviewers(candidates, count)
+	if len(reviewers) == 0 {
+		log.Warn("No reviewers can be requested.")
+		return nil
+	}
+
+	log.Infof("Requesting reviews from: %v.", reviewers)
+	return this.gc.AssignPR(org, repo, number, reviewers)
+}
+
+func (this *blunderbuss) orgRepoConfig(org, repo string) (*pluginConfig, error) {
+	cfg, err := this.pluginConfig(org, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.BlunderbussFor(org, repo), nil
+}
+
+func (this *blunderbuss) pluginConfig(org, repo string) (*configuration, error) {
+	c := this.getPluginConfig(this.PluginName(), org, repo)
+	if c == nil {
+		return nil, fmt.Errorf("can't find the configuration")
+	}
+
+	c1, ok := c.(*configuration)
+	if !ok {
+		return nil, fmt.Errorf("can't convert to configuration")
+	}
+
+	return c1, nil
+}
+
+// reviewersClient is the part of repoowners.RepoOwner used to find the
+// reviewers of files.
+type reviewersClient interface {
+	Reviewers(path string) sets.String
+}
+
+// potentialReviewers returns the reviewers of the changed files with their
+// weights, which are the sums of the weights of the files they review.
+func potentialReviewers(rc reviewersClient, changes []github.PullRequestChange, excluded sets.String) map[string]int64 {
+	r := map[string]int64{}
+	for _, change := range changes {
+		// Judge file size on a log scale, so that a few huge files don't
+		// outweigh all the others.
+		weight := int64(1)
+		if change.Changes > 0 {
+			weight = int64(math.Log10(float64(change.Changes))) + 1
+		}
+
+		for _, u := range rc.Reviewers(change.Filename).List() {
+			if !excluded.Has(u) {
+				r[u] += weight
+			}
+		}
+	}
+	return r
+}
+
+// selectReviewers randomly selects at most count reviewers from candidates
+// according to their weights.
+func selectReviewers(candidates map[string]int64, count int) []string {
+	users := sets.StringKeySet(candidates).List()
+
+	sum := int64(0)
+	for _, u := range users {
+		sum += candidates[u]
+	}
+
+	var r []string
+	for len(r) < count && len(users) > 0 && sum > 0 {
+		selection := rand.Int63n(sum)
+
+		i := 0
+		for ; i < len(users)-1; i++ {
+			selection -= candidates[users[i]]
+			if selection < 0 {
+				break
+			}
+		}
+
+		r = append(r, users[i])
+		sum -= candidates[users[i]]
+		users = append(users[:i], users[i+1:]...)
+	}
+	return r
+}
@@ -0,0 +1,65 @@
+package blunderbuss
+
+import (
+	"fmt"
+
+	"github.com/huaweicloud/golangsdk"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+// defaultReviewerCount is the number of reviewers requested for the repo
+// which is not configured.
+const defaultReviewerCount = 2
+
+type configuration struct {
+	Blunderbuss []pluginConfig `json:"blunderbuss,omitempty"`
+}
+
+func (c *configuration) Validate() error {
+	if _, err := golangsdk.BuildRequestBody(c, ""); err != nil {
+		return err
+	}
+
+	for i := range c.Blunderbuss {
+		if n := c.Blunderbuss[i].ReviewerCount; n < 1 {
+			return fmt.Errorf("reviewer_count must be positive, but it is %d", n)
+		}
+	}
+	return nil
+}
+
+func (c *configuration) SetDefault() {
+	for i := range c.Blunderbuss {
+		item := &(c.Blunderbuss[i])
+
+		if item.ReviewerCount == 0 {
+			item.ReviewerCount = defaultReviewerCount
+		}
+	}
+}
+
+// BlunderbussFor returns the config for the repo. The config of repo takes
+// precedence over the one of its org. The default is returned if neither is
+// set.
+func (c *configuration) BlunderbussFor(org, repo string) *pluginConfig {
+	i := plugins.FindConfig(org, repo, len(c.Blunderbuss), func(i int) []string {
+		return c.Blunderbuss[i].Repos
+	})
+	if i < 0 {
+		return &pluginConfig{ReviewerCount: defaultReviewerCount}
+	}
+	return &(c.Blunderbuss[i])
+}
+
+type pluginConfig struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos" required:"true"`
+
+	// ReviewerCount is the number of reviewers to request. Defaults to 2.
+	ReviewerCount int `json:"reviewer_count,omitempty"`
+
+	// FallbackReviewers are the users requested when no OWNERS file covers
+	// the changed files, such as the maintainers of the repo.
+	FallbackReviewers []string `json:"fallback_reviewers,omitempty"`
+}
@@ -0,0 +1,59 @@
+// Package builtin lists the plugins which are built into the gitee hook, so
+// that the hook and the tools checking its config share the same list.
+package builtin
+
+import (
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	prowv1 "k8s.io/test-infra/prow/client/clientset/versioned/typed/prowjobs/v1"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/repoowners"
+
+	"github.com/opensourceways/yabot/gitee/gitee"
+	"github.com/opensourceways/yabot/gitee/plugins"
+	"github.com/opensourceways/yabot/gitee/plugins/approve"
+	"github.com/opensourceways/yabot/gitee/plugins/assign"
+	"github.com/opensourceways/yabot/gitee/plugins/blunderbuss"
+	"github.com/opensourceways/yabot/gitee/plugins/cherrypick"
+	"github.com/opensourceways/yabot/gitee/plugins/cla"
+	"github.com/opensourceways/yabot/gitee/plugins/freeze"
+	"github.com/opensourceways/yabot/gitee/plugins/label"
+	"github.com/opensourceways/yabot/gitee/plugins/lgtm"
+	"github.com/opensourceways/yabot/gitee/plugins/merge"
+	"github.com/opensourceways/yabot/gitee/plugins/trigger"
+	"github.com/opensourceways/yabot/gitee/plugins/updateconfig"
+)
+
+// Clients holds what the built-in plugins depend on. All of them can be nil
+// if the plugins won't handle any event, such as to check the config.
+type Clients struct {
+	ProwConfig        prowConfig.Getter
+	PluginConfig      plugins.GetPluginConfig
+	RepoPluginConfig  plugins.GetRepoPluginConfig
+	SkipCollaborators func(org, repo string) bool
+
+	GiteeClient     gitee.Client
+	GitClient       git.ClientFactory
+	OwnersClient    repoowners.Interface
+	ProwJobClient   prowv1.ProwJobInterface
+	ConfigMapClient corev1.ConfigMapsGetter
+}
+
+// Plugins returns the built-in plugins.
+func Plugins(c Clients) []plugins.Plugin {
+	rpc := c.RepoPluginConfig
+
+	return []plugins.Plugin{
+		cla.NewCLA(rpc, c.GiteeClient),
+		updateconfig.NewUpdateConfig(c.PluginConfig, c.GiteeClient, c.GitClient, c.ConfigMapClient),
+		lgtm.NewLGTM(rpc, c.GiteeClient, c.OwnersClient, c.SkipCollaborators),
+		approve.NewApprove(rpc, c.GiteeClient, c.OwnersClient),
+		merge.NewMerge(rpc, c.GiteeClient, freeze.Checker(rpc)),
+		freeze.NewFreeze(rpc, c.GiteeClient),
+		label.NewLabel(rpc, c.GiteeClient),
+		assign.NewAssign(c.GiteeClient),
+		blunderbuss.NewBlunderbuss(rpc, c.GiteeClient, c.OwnersClient),
+		trigger.NewTrigger(rpc, c.ProwConfig, c.GitClient, c.GiteeClient, c.ProwJobClient),
+		cherrypick.NewCherryPick(c.GiteeClient, c.GitClient),
+	}
+}
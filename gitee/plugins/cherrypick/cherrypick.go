@@ -0,0 +1,299 @@
+package cherrypick
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/pluginhelp"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+	originp "github.com/opensourceways/yabot/prow/plugins"
+)
+
+const pluginName = "cherry-pick"
+
+var cherryPickRe = regexp.MustCompile(`(?m)^/cherry-pick\s+(\S+)\s*$`)
+
+type cherryPick struct {
+	gc        giteeClient
+	gitClient git.ClientFactory
+}
+
+// NewCherryPick returns the cherry-pick plugin. The commits of pull request
+// are applied onto the target branch in the fork of bot, from which a new
+// pull request is opened.
+func NewCherryPick(gc giteeClient, gitClient git.ClientFactory) plugins.Plugin {
+	return &cherryPick{
+		gc:        gc,
+		gitClient: gitClient,
+	}
+}
+
+func (this *cherryPick) HelpProvider(_ []prowConfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	pluginHelp := &pluginhelp.PluginHelp{
+		Description: "The cherry-pick plugin is used for cherry-picking pull requests across branches. The commits of pull request are applied onto the target branch in the fork of bot, which must exist, and a new pull request is opened from it. The result is commented on the original pull request.",
+	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/cherry-pick <branch>",
+		Description: "Cherrypick a PR to a different branch. This command works both in merged PRs (the cherrypick PR is opened immediately) and open PRs (the cherrypick PR opens as soon as the original PR merges).",
+		Featured:    true,
+		WhoCanUse:   "Anyone",
+		Examples:    []string{"/cherry-pick release-3.9"},
+	})
+	return pluginHelp, nil
+}
+
+func (this *cherryPick) PluginName() string {
+	return pluginName
+}
+
+func (this *cherryPick) NewPluginConfig() plugins.PluginConfig {
+	return nil
+}
+
+func (this *cherryPick) RegisterEventHandler(p plugins.Plugins) {
+	name := this.PluginName()
+	p.RegisterNoteEventHandler(name, this.handleNoteEvent)
+	p.RegisterPullRequestHandler(name, this.handlePullRequestEvent)
+}
+
+func (this *cherryPick) handleNoteEvent(e *sdk.NoteEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handleNoteEvent")
+	}()
+
+	if *(e.Action) != "comment" {
+		log.Debug("Event is not a creation of a comment, skipping.")
+		return nil
+	}
+
+	if *(e.NoteableType) != "PullRequest" {
+		return nil
+	}
+
+	branches := parseBranches(e.Comment.Body)
+	if len(branches) == 0 {
+		return nil
+	}
+
+	pr := e.PullRequest
+	org := e.Repository.Namespace
+	repo := e.Repository.Path
+	requestor := e.Comment.User.Login
+
+	reply := func(resp string) error {
+		msg := originp.FormatResponseRaw(e.Comment.Body, e.Comment.HtmlUrl, requestor, resp)
+		return this.gc.CreatePRComment(org, repo, int(pr.Number), msg)
+	}
+
+	if pr.State != "merged" {
+		if pr.State != "open" {
+			return reply("The pull request is closed without being merged, so it can't be cherry-picked.")
+		}
+
+		// The command is found in the comments when it is merged.
+		return reply(fmt.Sprintf("Once this pull request is merged, I will cherry-pick it on top of %s in a new pull request.", strings.Join(branches, ", ")))
+	}
+
+	var errs []error
+	for _, branch := range branches {
+		if err := this.handle(org, repo, pr, branch, requestor, reply, log); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (this *cherryPick) handlePullRequestEvent(e *sdk.PullRequestEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handlePullRequest")
+	}()
+
+	if strings.ToLower(*(e.Action)) != "merge" {
+		return nil
+	}
+
+	pr := e.PullRequest
+	org := pr.Base.Repo.Namespace
+	repo := pr.Base.Repo.Path
+	number := int(pr.Number)
+
+	botName, err := this.gc.BotName()
+	if err != nil {
+		return err
+	}
+
+	comments, err := this.gc.ListPRComments(org, repo, number)
+	if err != nil {
+		return err
+	}
+
+	// Each branch is cherry-picked once for the first one requesting it.
+	done := sets.NewString()
+	var errs []error
+	for _, c := range comments {
+		if c.User == nil || c.User.Login == botName {
+			continue
+		}
+
+		requestor := c.User.Login
+		reply := func(resp string) error {
+			msg := originp.FormatResponseRaw(c.Body, c.HtmlUrl, requestor, resp)
+			return this.gc.CreatePRComment(org, repo, number, msg)
+		}
+
+		for _, branch := range parseBranches(c.Body) {
+			if done.Has(branch) {
+				continue
+			}
+			done.Insert(branch)
+
+			if err := this.handle(org, repo, pr, branch, requestor, reply, log); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// handle cherry-picks the merged pull request onto the target branch, and
+// replies the new pull request or the reason of failure.
+func (this *cherryPick) handle(org, repo string, pr *sdk.PullRequestHook, target, requestor string, reply func(string) error, log *logrus.Entry) error {
+	if target == pr.Base.Ref {
+		return reply(fmt.Sprintf("Base branch (%s) needs to differ from target branch (%s).", pr.Base.Ref, target))
+	}
+
+	number := int(pr.Number)
+	newBranch := fmt.Sprintf("cherry-pick-%d-to-%s", number, target)
+	l := log.WithFields(logrus.Fields{"target_branch": target, "new_branch": newBranch})
+
+	// Every failure is replied, otherwise the requestor can't know why
+	// no pull request is opened.
+	fail := func(msg string, err error) error {
+		l.WithError(err).Warn(msg)
+		return reply(fmt.Sprintf("%s: %v", msg, err))
+	}
+
+	botName, err := this.gc.BotName()
+	if err != nil {
+		return fail("Failed to get the name of bot", err)
+	}
+
+	r, err := this.gitClient.ClientFor(org, repo)
+	if err != nil {
+		return fail(fmt.Sprintf("Failed to clone %s/%s", org, repo), err)
+	}
+	defer func() {
+		if err := r.Clean(); err != nil {
+			l.WithError(err).Error("Error cleaning up repo.")
+		}
+	}()
+
+	dir, err := ioutil.TempDir("", "cherry-pick")
+	if err != nil {
+		return fail("Failed to create the directory of patches", err)
+	}
+	defer os.RemoveAll(dir)
+
+	patches, err := formatPatches(r, pr, dir, l)
+	if err != nil {
+		return fail(fmt.Sprintf("Failed to get the commits of #%d", number), err)
+	}
+
+	if err := r.Checkout(target); err != nil {
+		return fail(fmt.Sprintf("Cannot checkout `%s`", target), err)
+	}
+
+	if err := r.CheckoutNewBranch(newBranch); err != nil {
+		return fail(fmt.Sprintf("Failed to create the branch `%s`", newBranch), err)
+	}
+
+	for _, patch := range patches {
+		if err := r.Am(patch); err != nil {
+			l.WithError(err).Warn("Failed to apply the patch.")
+			return reply(fmt.Sprintf("#%d failed to apply on top of branch `%s`:\n```\n%v\n```", number, target, err))
+		}
+	}
+
+	if err := r.ForcePush(newBranch); err != nil {
+		return fail(fmt.Sprintf("Failed to push the branch `%s` to the fork of %s", newBranch, botName), err)
+	}
+
+	title := fmt.Sprintf("[%s] %s", target, pr.Title)
+	body := fmt.Sprintf("This is an automated cherry-pick of %s\n\nRequested by @%s", pr.HtmlUrl, requestor)
+	head := fmt.Sprintf("%s:%s", botName, newBranch)
+
+	created, err := this.gc.CreatePullRequest(org, repo, title, body, head, target, true)
+	if err != nil {
+		return fail("New pull request could not be created", err)
+	}
+
+	l.Infof("Created the pull request: %s.", created.HtmlUrl)
+	return reply(fmt.Sprintf("New pull request created: %s", created.HtmlUrl))
+}
+
+// formatPatches writes the commits of pull request as patches to dir, and
+// returns their paths in order. The commits are fetched through the git
+// client, so that they are available for the private repository too.
+func formatPatches(r git.RepoClient, pr *sdk.PullRequestHook, dir string, log *logrus.Entry) ([]string, error) {
+	if err := r.FetchRef(fmt.Sprintf("pull/%d/head", pr.Number)); err != nil {
+		return nil, err
+	}
+
+	e, err := git.NewCensoringExecutor(r.Directory(), func(b []byte) []byte { return b }, log)
+	if err != nil {
+		return nil, err
+	}
+	run := func(args ...string) (string, error) {
+		out, err := e.Run(args...)
+		if err != nil {
+			return "", fmt.Errorf("error running git %s: %v %s", args[0], err, string(out))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	head, err := run("rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	// The base branch right before the merge doesn't include the commits of
+	// pull request, whichever merge method is used.
+	base := "origin/" + pr.Base.Ref
+	if pr.MergeCommitSha != "" {
+		base = pr.MergeCommitSha + "^"
+	}
+	mergeBase, err := run("merge-base", base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := run("format-patch", "-o", dir, mergeBase+".."+head)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, fmt.Errorf("no commits between %s and %s", mergeBase, head)
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+func parseBranches(body string) []string {
+	r := sets.NewString()
+	for _, m := range cherryPickRe.FindAllStringSubmatch(body, -1) {
+		r.Insert(m[1])
+	}
+	return r.List()
+}
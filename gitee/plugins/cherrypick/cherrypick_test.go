@@ -0,0 +1,352 @@
+package cherrypick
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/git/localgit"
+	"k8s.io/test-infra/prow/git/v2"
+
+	"github.com/opensourceways/yabot/gitee/flagutil"
+)
+
+const (
+	testOrg    = "org"
+	testRepo   = "repo"
+	testNumber = 3
+	testBot    = "bot"
+	baseBranch = "master"
+)
+
+type fakeGiteeClient struct {
+	comments []sdk.PullRequestComments
+
+	replies []string
+	// created are the heads and bases of created pull requests.
+	created []string
+}
+
+func (f *fakeGiteeClient) BotName() (string, error) {
+	return testBot, nil
+}
+
+func (f *fakeGiteeClient) ListPRComments(org, repo string, number int) ([]sdk.PullRequestComments, error) {
+	return f.comments, nil
+}
+
+func (f *fakeGiteeClient) CreatePRComment(owner, repo string, number int, comment string) error {
+	f.replies = append(f.replies, comment)
+	return nil
+}
+
+func (f *fakeGiteeClient) CreatePullRequest(org, repo, title, body, head, base string, canModify bool) (sdk.PullRequest, error) {
+	f.created = append(f.created, head+"->"+base)
+	return sdk.PullRequest{HtmlUrl: "https://gitee.com/org/repo/pulls/100"}, nil
+}
+
+// testRepoState is the repo in which a pull request adding file "a" is
+// merged to master, and the branches which it can be cherry-picked to are
+// created before the merge.
+type testRepoState struct {
+	lg        *localgit.LocalGit
+	gitClient git.ClientFactory
+
+	pr *sdk.PullRequestHook
+}
+
+func makeTestRepo(t *testing.T, merged bool) *testRepoState {
+	lg, gitClient, err := localgit.NewV2()
+	if err != nil {
+		t.Fatalf("Making local git repo: %v", err)
+	}
+
+	// The changes are pushed to the remote repo which is cloned.
+	publishRemote := func(org, repo string) (string, error) {
+		return filepath.Join(lg.Dir, org, repo), nil
+	}
+	s := &testRepoState{
+		lg:        lg,
+		gitClient: flagutil.NewPushableClientFactory(gitClient, publishRemote, func(b []byte) []byte { return b }),
+	}
+	if err := lg.MakeFakeRepo(testOrg, testRepo); err != nil {
+		t.Fatalf("Making fake repo: %v", err)
+	}
+	if err := lg.CheckoutNewBranch(testOrg, testRepo, "release"); err != nil {
+		t.Fatalf("Checking out branch: %v", err)
+	}
+	// The file "a" of branch conflict can't accept the pull request.
+	if err := lg.CheckoutNewBranch(testOrg, testRepo, "conflict"); err != nil {
+		t.Fatalf("Checking out branch: %v", err)
+	}
+	if err := lg.AddCommit(testOrg, testRepo, map[string][]byte{"a": []byte("conflict")}); err != nil {
+		t.Fatalf("Adding commit: %v", err)
+	}
+
+	if err := lg.Checkout(testOrg, testRepo, baseBranch); err != nil {
+		t.Fatalf("Checking out base branch: %v", err)
+	}
+	if err := lg.CheckoutNewBranch(testOrg, testRepo, "feature"); err != nil {
+		t.Fatalf("Checking out branch: %v", err)
+	}
+	if err := lg.AddCommit(testOrg, testRepo, map[string][]byte{"a": []byte("feature")}); err != nil {
+		t.Fatalf("Adding commit: %v", err)
+	}
+	head, err := lg.RevParse(testOrg, testRepo, "HEAD")
+	if err != nil {
+		t.Fatalf("Getting the head of pull request: %v", err)
+	}
+	s.git(t, "update-ref", "refs/pull/3/head", head)
+
+	// The base branch moves after the pull request is opened.
+	if err := lg.Checkout(testOrg, testRepo, baseBranch); err != nil {
+		t.Fatalf("Checking out base branch: %v", err)
+	}
+	if err := lg.AddCommit(testOrg, testRepo, map[string][]byte{"b": []byte("base")}); err != nil {
+		t.Fatalf("Adding commit: %v", err)
+	}
+
+	s.pr = &sdk.PullRequestHook{
+		Number:  testNumber,
+		State:   "open",
+		Title:   "add a",
+		HtmlUrl: "https://gitee.com/org/repo/pulls/3",
+		Base: &sdk.BranchHook{
+			Ref:  baseBranch,
+			Repo: &sdk.ProjectHook{Namespace: testOrg, Path: testRepo},
+		},
+	}
+
+	if merged {
+		if _, err := lg.Merge(testOrg, testRepo, head); err != nil {
+			t.Fatalf("Merging pull request: %v", err)
+		}
+		sha, err := lg.RevParse(testOrg, testRepo, "HEAD")
+		if err != nil {
+			t.Fatalf("Getting the merge commit: %v", err)
+		}
+		s.pr.State = "merged"
+		s.pr.MergeCommitSha = sha
+	}
+	return s
+}
+
+func (s *testRepoState) clean(t *testing.T) {
+	if err := s.lg.Clean(); err != nil {
+		t.Errorf("Cleaning up localgit: %v", err)
+	}
+	if err := s.gitClient.Clean(); err != nil {
+		t.Errorf("Cleaning up client: %v", err)
+	}
+}
+
+func (s *testRepoState) git(t *testing.T, args ...string) string {
+	c := exec.Command(s.lg.Git, args...)
+	c.Dir = filepath.Join(s.lg.Dir, testOrg, testRepo)
+	out, err := c.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Running git %v: %v %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// checkPushed checks that the pushed branch has the files with the content.
+func (s *testRepoState) checkPushed(t *testing.T, branch string, files map[string]string) {
+	for f, c := range files {
+		if v := s.git(t, "show", branch+":"+f); v != c {
+			t.Errorf("Expected the content %q of %s in branch %s, but got %q", c, f, branch, v)
+		}
+	}
+}
+
+func setGitIdentity(t *testing.T) func() {
+	env := map[string]string{
+		"GIT_COMMITTER_NAME":  "robot",
+		"GIT_COMMITTER_EMAIL": "robot@beep.boop",
+	}
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatalf("Setting %s: %v", k, err)
+		}
+	}
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func checkReplies(t *testing.T, replies, expected []string) {
+	if len(replies) != len(expected) {
+		t.Fatalf("Expected %d replies, but got %d: %v", len(expected), len(replies), replies)
+	}
+	for i, v := range expected {
+		if !strings.Contains(replies[i], v) {
+			t.Errorf("Expected reply %d to contain %q, but got %q", i, v, replies[i])
+		}
+	}
+}
+
+func TestHandleNoteEvent(t *testing.T) {
+	defer setGitIdentity(t)()
+
+	testcases := []struct {
+		name   string
+		body   string
+		merged bool
+
+		expectedReplies []string
+		expectedCreated []string
+	}{
+		{
+			name: "plain comment",
+			body: "looks good",
+		},
+		{
+			name:            "requests on open pull request",
+			body:            "/cherry-pick release",
+			expectedReplies: []string{"Once this pull request is merged, I will cherry-pick it on top of release"},
+		},
+		{
+			name:            "requests on merged pull request",
+			body:            "/cherry-pick release",
+			merged:          true,
+			expectedReplies: []string{"New pull request created"},
+			expectedCreated: []string{"bot:cherry-pick-3-to-release->release"},
+		},
+		{
+			name:            "requests the base branch",
+			body:            "/cherry-pick master",
+			merged:          true,
+			expectedReplies: []string{"Base branch (master) needs to differ from target branch (master)"},
+		},
+		{
+			name:            "conflicts with the target branch",
+			body:            "/cherry-pick conflict",
+			merged:          true,
+			expectedReplies: []string{"#3 failed to apply on top of branch `conflict`"},
+		},
+		{
+			name:            "target branch doesn't exist",
+			body:            "/cherry-pick unknown",
+			merged:          true,
+			expectedReplies: []string{"Cannot checkout `unknown`"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := makeTestRepo(t, tc.merged)
+			defer s.clean(t)
+
+			gc := &fakeGiteeClient{}
+			p := NewCherryPick(gc, s.gitClient).(*cherryPick)
+
+			action := "comment"
+			noteableType := "PullRequest"
+			e := &sdk.NoteEvent{
+				Action:       &action,
+				NoteableType: &noteableType,
+				Comment: &sdk.NoteHook{
+					Body: tc.body,
+					User: &sdk.UserHook{Login: "requestor"},
+				},
+				PullRequest: s.pr,
+				Repository:  &sdk.ProjectHook{Namespace: testOrg, Path: testRepo},
+			}
+			if err := p.handleNoteEvent(e, logrus.WithField("plugin", pluginName)); err != nil {
+				t.Fatalf("Handling note event: %v", err)
+			}
+
+			checkReplies(t, gc.replies, tc.expectedReplies)
+			if !reflect.DeepEqual(gc.created, tc.expectedCreated) {
+				t.Errorf("Expected created pull requests %v, but got %v", tc.expectedCreated, gc.created)
+			}
+			if len(tc.expectedCreated) > 0 {
+				// The commit of base branch after the pull request is
+				// opened is not cherry-picked.
+				s.checkPushed(t, "cherry-pick-3-to-release", map[string]string{"a": "feature"})
+				for _, f := range strings.Split(s.git(t, "ls-tree", "--name-only", "cherry-pick-3-to-release"), "\n") {
+					if f == "b" {
+						t.Errorf("Unexpected file b in the cherry-picked branch")
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHandlePullRequestEvent(t *testing.T) {
+	defer setGitIdentity(t)()
+
+	comment := func(user, body string) sdk.PullRequestComments {
+		return sdk.PullRequestComments{Body: body, User: &sdk.UserBasic{Login: user}}
+	}
+
+	testcases := []struct {
+		name     string
+		comments []sdk.PullRequestComments
+
+		expectedReplies []string
+		expectedCreated []string
+	}{
+		{
+			name: "no request",
+			comments: []sdk.PullRequestComments{
+				comment("requestor", "looks good"),
+			},
+		},
+		{
+			name: "cherry-picks once for the branch requested several times",
+			comments: []sdk.PullRequestComments{
+				comment("requestor", "/cherry-pick release"),
+				comment("other", "/cherry-pick release"),
+				comment(testBot, "/cherry-pick conflict"),
+			},
+			expectedReplies: []string{"New pull request created"},
+			expectedCreated: []string{"bot:cherry-pick-3-to-release->release"},
+		},
+		{
+			name: "replies each branch",
+			comments: []sdk.PullRequestComments{
+				comment("requestor", "/cherry-pick release\n/cherry-pick master"),
+				comment("other", "/cherry-pick conflict"),
+			},
+			expectedReplies: []string{
+				"Base branch (master) needs to differ from target branch (master)",
+				"New pull request created",
+				"#3 failed to apply on top of branch `conflict`",
+			},
+			expectedCreated: []string{"bot:cherry-pick-3-to-release->release"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := makeTestRepo(t, true)
+			defer s.clean(t)
+
+			gc := &fakeGiteeClient{comments: tc.comments}
+			p := NewCherryPick(gc, s.gitClient).(*cherryPick)
+
+			action := "merge"
+			e := &sdk.PullRequestEvent{
+				Action:      &action,
+				PullRequest: s.pr,
+			}
+			if err := p.handlePullRequestEvent(e, logrus.WithField("plugin", pluginName)); err != nil {
+				t.Fatalf("Handling pull request event: %v", err)
+			}
+
+			checkReplies(t, gc.replies, tc.expectedReplies)
+			if !reflect.DeepEqual(gc.created, tc.expectedCreated) {
+				t.Errorf("Expected created pull requests %v, but got %v", tc.expectedCreated, gc.created)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package cherrypick
+
+import (
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+)
+
+type giteeClient interface {
+	BotName() (string, error)
+	ListPRComments(org, repo string, number int) ([]sdk.PullRequestComments, error)
+	CreatePRComment(owner, repo string, number int, comment string) error
+	CreatePullRequest(org, repo, title, body, head, base string, canModify bool) (sdk.PullRequest, error)
+}
@@ -22,11 +22,11 @@ var (
 )
 
 type cla struct {
-	getPluginConfig plugins.GetPluginConfig
+	getPluginConfig plugins.GetRepoPluginConfig
 	ghc             *ghclient
 }
 
-func NewCLA(f plugins.GetPluginConfig, gec giteeClient) plugins.Plugin {
+func NewCLA(f plugins.GetRepoPluginConfig, gec giteeClient) plugins.Plugin {
 	return &cla{
 		getPluginConfig: f,
 		ghc:             &ghclient{giteeClient: gec},
@@ -185,7 +185,7 @@ func (this *cla) handlePullRequestEvent(e *sdk.PullRequestEvent, log *logrus.Ent
 }
 
 func (this *cla) orgRepoConfig(org, repo string) (*pluginConfig, error) {
-	cfg, err := this.pluginConfig()
+	cfg, err := this.pluginConfig(org, repo)
 	if err != nil {
 		return nil, err
 	}
@@ -198,8 +198,8 @@ func (this *cla) orgRepoConfig(org, repo string) (*pluginConfig, error) {
 	return pc, nil
 }
 
-func (this *cla) pluginConfig() (*configuration, error) {
-	c := this.getPluginConfig(this.PluginName())
+func (this *cla) pluginConfig(org, repo string) (*configuration, error) {
+	c := this.getPluginConfig(this.PluginName(), org, repo)
 	if c == nil {
 		return nil, fmt.Errorf("can't find the configuration")
 	}
@@ -1,10 +1,16 @@
 package cla
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/huaweicloud/golangsdk"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
 )
 
 type configuration struct {
@@ -43,6 +49,53 @@ func (c *configuration) CLAFor(org, repo string) *pluginConfig {
 	return nil
 }
 
+// CheckEnabledRepos checks that each org and repo enabling cla has its config.
+func (c *configuration) CheckEnabledRepos(orgs, repos []string) error {
+	var errs []error
+	for _, org := range orgs {
+		if c.CLAFor(org, "") == nil {
+			errs = append(errs, fmt.Errorf("cla is enabled for %s, but no cla config for it", org))
+		}
+	}
+
+	for _, repo := range repos {
+		v := strings.SplitN(repo, "/", 2)
+		if c.CLAFor(v[0], v[1]) == nil {
+			errs = append(errs, fmt.Errorf("cla is enabled for %s, but no cla config for it", repo))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// OverrideFor returns the config for the repo, in which the labels are
+// overridden by the config in repo. The other settings can't be overridden.
+func (c *configuration) OverrideFor(org, repo string, b []byte) (plugins.PluginConfig, error) {
+	pc := c.CLAFor(org, repo)
+	if pc == nil {
+		return nil, fmt.Errorf("no cla config for %s/%s", org, repo)
+	}
+
+	var o struct {
+		CLALabelYes string `json:"cla_label_yes,omitempty"`
+		CLALabelNo  string `json:"cla_label_no,omitempty"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		return nil, err
+	}
+
+	v := *pc
+	v.Repos = []string{fmt.Sprintf("%s/%s", org, repo)}
+	if o.CLALabelYes != "" {
+		v.CLALabelYes = o.CLALabelYes
+	}
+	if o.CLALabelNo != "" {
+		v.CLALabelNo = o.CLALabelNo
+	}
+	return &configuration{CLA: []pluginConfig{v}}, nil
+}
+
 type pluginConfig struct {
 	// Repos is either of the form org/repos or just org.
 	Repos []string `json:"repos" required:"true"`
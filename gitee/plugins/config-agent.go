@@ -1,27 +1,96 @@
 package plugins
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
+const (
+	// configResyncPeriod is how often the plugin config is reloaded even if
+	// no change is notified, in case some changes are not watched.
+	configResyncPeriod = time.Minute
+	// configReloadDelay merges the notifications of a change which is made
+	// by several writes, such as the symlink swap of a ConfigMap.
+	configReloadDelay = time.Second
+)
+
+var (
+	pluginConfigInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitee_plugin_config_info",
+		Help: "The hash of plugin config which is in use. It is always 1.",
+	}, []string{"path", "hash"})
+	pluginConfigLastReload = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitee_plugin_config_last_reload_timestamp_seconds",
+		Help: "The time when the plugin config was loaded successfully last time.",
+	}, []string{"path"})
+	pluginConfigReloadErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitee_plugin_config_reload_errors_total",
+		Help: "The number of failed loadings of plugin config.",
+	}, []string{"path"})
+)
+
+func init() {
+	prometheus.MustRegister(pluginConfigInfo)
+	prometheus.MustRegister(pluginConfigLastReload)
+	prometheus.MustRegister(pluginConfigReloadErrors)
+}
+
 type PluginConfigBuilder func() PluginConfig
 
+// ConfigChangeHandler is called with the previous and the new plugin config
+// after the plugin config is changed.
+type ConfigChangeHandler func(old, new *Configurations)
+
+// ConfigStatus describes the plugin config which is in use, and the failure
+// of the latest loading if any. The config in use is the last known good one
+// when the latest loading fails. The failure is cleared by the next success.
+type ConfigStatus struct {
+	Path          string    `json:"path"`
+	Hash          string    `json:"hash"`
+	LastReload    time.Time `json:"last_reload,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time,omitempty"`
+}
+
 type ConfigAgent struct {
-	mut sync.RWMutex
-	c   *Configurations
-	pcb map[string]PluginConfigBuilder
+	mut    sync.RWMutex
+	c      *Configurations
+	pcb    map[string]PluginConfigBuilder
+	status ConfigStatus
+
+	handlers []ConfigChangeHandler
 }
 
 func NewConfigAgent() *ConfigAgent {
 	return &ConfigAgent{pcb: map[string]PluginConfigBuilder{}}
 }
 
+// Load loads the plugin config at path, which is either a file or a directory
+// of files to be merged. The config in use is kept if it fails.
 func (ca *ConfigAgent) Load(path string, checkUnknownPlugins bool, knownPlugins map[string]HelpProvider) error {
+	c, hash, err := ca.parse(path, checkUnknownPlugins, knownPlugins)
+	if err != nil {
+		ca.recordError(path, err)
+		return err
+	}
+
+	ca.update(path, c, hash)
+	return nil
+}
+
+func (ca *ConfigAgent) parse(path string, checkUnknownPlugins bool, knownPlugins map[string]HelpProvider) (*Configurations, string, error) {
 	pcs := make(map[string]PluginConfig)
 	for n, b := range ca.pcb {
 		v := b()
@@ -32,36 +101,90 @@ func (ca *ConfigAgent) Load(path string, checkUnknownPlugins bool, knownPlugins
 
 	c := &Configurations{pluginConfigs: pcs}
 
-	err := load(path, c)
+	b, err := readConfig(path)
 	if err != nil {
-		return err
+		return nil, "", err
+	}
+
+	if err := load(b, c); err != nil {
+		return nil, "", err
 	}
 
 	if checkUnknownPlugins {
 		var errors []error
 		for _, ps := range c.Plugins {
-			for _, p := range ps {
+			for _, p := range ps.Names() {
 				if h, ok := knownPlugins[p]; !ok || (h == nil) {
 					errors = append(errors, fmt.Errorf("unknown plugin: %s", p))
 				}
 			}
 		}
 		if len(errors) > 0 {
-			return utilerrors.NewAggregate(errors)
+			return nil, "", utilerrors.NewAggregate(errors)
 		}
 	}
 
+	sum := sha256.Sum256(b)
+	return c, hex.EncodeToString(sum[:]), nil
+}
+
+func (ca *ConfigAgent) update(path string, c *Configurations, hash string) {
+	now := time.Now()
+
 	ca.mut.Lock()
-	defer ca.mut.Unlock()
+	old := ca.c
+	changed := ca.status.Hash != hash
 	ca.c = c
+	ca.status.Path = path
+	ca.status.Hash = hash
+	ca.status.LastReload = now
+	ca.status.LastError = ""
+	ca.status.LastErrorTime = time.Time{}
+	handlers := ca.handlers
+	ca.mut.Unlock()
 
-	return nil
+	pluginConfigLastReload.WithLabelValues(path).Set(float64(now.Unix()))
+
+	if !changed {
+		return
+	}
+
+	pluginConfigInfo.Reset()
+	pluginConfigInfo.WithLabelValues(path, hash).Set(1)
+
+	if old == nil {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{"path": path, "hash": hash}).Info("Plugin config is changed.")
+	for _, h := range handlers {
+		h(old, c)
+	}
+}
+
+func (ca *ConfigAgent) recordError(path string, err error) {
+	ca.mut.Lock()
+	ca.status.Path = path
+	ca.status.LastError = err.Error()
+	ca.status.LastErrorTime = time.Now()
+	ca.mut.Unlock()
+
+	pluginConfigReloadErrors.WithLabelValues(path).Inc()
 }
 
 func (ca *ConfigAgent) RegisterPluginConfigBuilder(name string, b PluginConfigBuilder) {
 	ca.pcb[name] = b
 }
 
+// RegisterConfigChangeHandler registers a handler which is called after the
+// plugin config is changed. It is not called for the first loading.
+func (ca *ConfigAgent) RegisterConfigChangeHandler(h ConfigChangeHandler) {
+	ca.mut.Lock()
+	defer ca.mut.Unlock()
+
+	ca.handlers = append(ca.handlers, h)
+}
+
 func (ca *ConfigAgent) Config() *Configurations {
 	ca.mut.Lock()
 	defer ca.mut.Unlock()
@@ -69,8 +192,32 @@ func (ca *ConfigAgent) Config() *Configurations {
 	return ca.c
 }
 
-// Start starts polling path for plugin config. If the first attempt fails,
-// then start returns the error. Future errors will halt updates but not stop.
+// Status returns the status of plugin config.
+func (ca *ConfigAgent) Status() ConfigStatus {
+	ca.mut.RLock()
+	defer ca.mut.RUnlock()
+
+	return ca.status
+}
+
+// ServeHTTP serves the status of plugin config as JSON.
+func (ca *ConfigAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := json.Marshal(ca.Status())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("500 Internal server error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// Start starts watching path for plugin config. If the first attempt fails,
+// then start returns the error. Future errors will halt updates but not stop,
+// and the last known good config is kept in use. The directory of path, or
+// path itself if it is a directory, is watched, so that the symlink swap of a
+// mounted ConfigMap is noticed. The config is also reloaded periodically in
+// case a change is missed.
 // If checkUnknownPlugins is true, unrecognized plugin names will make config
 // loading fail.
 func (ca *ConfigAgent) Start(path string, checkUnknownPlugins bool, knownPlugins map[string]HelpProvider) error {
@@ -78,11 +225,51 @@ func (ca *ConfigAgent) Start(path string, checkUnknownPlugins bool, knownPlugins
 		return err
 	}
 
-	ticker := time.Tick(1 * time.Minute)
+	l := logrus.WithField("path", path)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	dir := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err == nil {
+		if err = w.Add(dir); err != nil {
+			w.Close()
+		} else {
+			events, errs = w.Events, w.Errors
+		}
+	}
+	if err != nil {
+		l.WithError(err).Warn("Failed to watch plugin config, falling back to polling.")
+	}
+
+	reload := func() {
+		if err := ca.Load(path, checkUnknownPlugins, knownPlugins); err != nil {
+			l.WithError(err).Error("Error loading plugin config.")
+		}
+	}
+
 	go func() {
-		for range ticker {
-			if err := ca.Load(path, checkUnknownPlugins, knownPlugins); err != nil {
-				logrus.WithField("path", path).WithError(err).Error("Error loading plugin config.")
+		ticker := time.NewTicker(configResyncPeriod)
+		defer ticker.Stop()
+
+		var delay <-chan time.Time
+		for {
+			select {
+			case e := <-events:
+				if e.Op != fsnotify.Chmod {
+					delay = time.After(configReloadDelay)
+				}
+			case err := <-errs:
+				l.WithError(err).Warn("Error watching plugin config.")
+			case <-delay:
+				delay = nil
+				reload()
+			case <-ticker.C:
+				reload()
 			}
 		}
 	}()
@@ -0,0 +1,199 @@
+package plugins
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// readConfig returns the content of plugin config at path. If path is a
+// directory, the content is merged from the yaml files in it, which are
+// usually one for each org.
+func readConfig(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return ioutil.ReadFile(path)
+	}
+
+	files, err := configFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config file in %s", path)
+	}
+	return mergeConfigFiles(files)
+}
+
+// configFiles returns the yaml files in dir in order. The hidden ones, such
+// as the "..data" of a mounted ConfigMap, are skipped.
+func configFiles(dir string) ([]string, error) {
+	items, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, item := range items {
+		name := item.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		ext := filepath.Ext(name)
+		if ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+
+		p := filepath.Join(dir, name)
+		// item is the symlink itself for the files of ConfigMap.
+		if info, err := os.Stat(p); err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, p)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// configMerger merges the sections of config files. The maps, such as plugins
+// and external_plugins, are merged by key, and each key can be defined in one
+// file only. The lists, such as the config of cla, are concatenated, and the
+// item of an org or repo, whose "repos" field has it, can be defined in one
+// file only. The other values must be the same if defined in several files.
+type configMerger struct {
+	merged map[string]interface{}
+	// sources records the file in which each map key or repo of a section
+	// is defined.
+	sources map[string]string
+	errs    []error
+}
+
+func mergeConfigFiles(files []string) ([]byte, error) {
+	m := configMerger{
+		merged:  map[string]interface{}{},
+		sources: map[string]string{},
+	}
+
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		var sections map[string]interface{}
+		if err := yaml.Unmarshal(b, &sections); err != nil {
+			return nil, fmt.Errorf("%s: %v", f, err)
+		}
+
+		for _, k := range sortedKeys(sections) {
+			m.mergeSection(k, sections[k], filepath.Base(f))
+		}
+	}
+
+	if len(m.errs) > 0 {
+		return nil, utilerrors.NewAggregate(m.errs)
+	}
+	return yaml.Marshal(m.merged)
+}
+
+func (m *configMerger) mergeSection(section string, v interface{}, file string) {
+	old, ok := m.merged[section]
+	if !ok || old == nil {
+		m.merged[section] = v
+		m.record(section, v, file)
+		return
+	}
+
+	switch nv := v.(type) {
+	case map[string]interface{}:
+		if ov, ok := old.(map[string]interface{}); ok {
+			m.record(section, nv, file)
+			for k, item := range nv {
+				ov[k] = item
+			}
+			return
+		}
+
+	case []interface{}:
+		if ov, ok := old.([]interface{}); ok {
+			m.record(section, nv, file)
+			m.merged[section] = append(ov, nv...)
+			return
+		}
+
+	default:
+		if reflect.DeepEqual(old, v) {
+			return
+		}
+	}
+
+	m.errs = append(m.errs, fmt.Errorf("%s: conflicting %s with %s", file, section, m.sources[section]))
+}
+
+// record records the file which defines the keys or repos of section, and
+// reports the ones defined in another file.
+func (m *configMerger) record(section string, v interface{}, file string) {
+	if _, ok := m.sources[section]; !ok {
+		m.sources[section] = file
+	}
+
+	var keys []string
+	switch nv := v.(type) {
+	case map[string]interface{}:
+		keys = sortedKeys(nv)
+
+	case []interface{}:
+		for _, item := range nv {
+			keys = append(keys, reposOfItem(item)...)
+		}
+	}
+
+	for _, k := range keys {
+		sk := section + "/" + k
+		if f, ok := m.sources[sk]; ok && f != file {
+			m.errs = append(m.errs, fmt.Errorf("%s: %s of %s is already defined in %s", file, k, section, f))
+			continue
+		}
+		m.sources[sk] = file
+	}
+}
+
+func reposOfItem(item interface{}) []string {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	repos, ok := m["repos"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	r := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		if s, ok := repo.(string); ok {
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -17,16 +17,30 @@ limitations under the License.
 package plugins
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/labels"
 	origin "k8s.io/test-infra/prow/plugins"
 	"sigs.k8s.io/yaml"
 )
 
+// The policies of handling the events caused by bots.
+const (
+	BotEventsDeliver = "deliver"
+	BotEventsSkip    = "skip"
+	BotEventsTag     = "tag"
+)
+
+// BotEventHeader is set to "true" for the events caused by bots when they
+// are delivered to external plugins with the "tag" policy.
+const BotEventHeader = "X-Hook-Bot-Event"
+
 type PluginConfig interface {
 	Validate() error
 	SetDefault()
@@ -34,12 +48,12 @@ type PluginConfig interface {
 
 // configuration is the top-level serialization target for plugin configuration.
 type Configurations struct {
-	// Plugins is a map of repositories (eg "k/k") to lists of
-	// plugin names.
+	// Plugins is a map of orgs or repositories (eg "k/k") to the
+	// plugins enabled for them, which can be a list of plugin names.
 	// You can find a comprehensive list of the default avaulable plugins here
 	// https://github.com/kubernetes/test-infra/tree/master/prow/plugins
 	// note that you're also able to add external plugins.
-	Plugins map[string][]string `json:"plugins,omitempty"`
+	Plugins map[string]OrgPlugins `json:"plugins,omitempty"`
 
 	// Owners contains configuration related to handling OWNERS files.
 	Owners origin.Owners `json:"owners,omitempty"`
@@ -48,10 +62,94 @@ type Configurations struct {
 	// external plugins.
 	ExternalPlugins map[string][]ExternalPlugin `json:"external_plugins,omitempty"`
 
+	// BotEvents configures how to handle the events caused by bots, which
+	// may make the plugins reacting to comments loop.
+	BotEvents BotEvents `json:"bot_events,omitempty"`
+
+	// RepoConfig limits what the repos can change by RepoConfigFile.
+	RepoConfig RepoConfigPolicy `json:"repo_config,omitempty"`
+
 	// Built-in plugins specific configuration.
 	pluginConfigs map[string]PluginConfig
 }
 
+// OrgPlugins holds the plugins enabled for an org or a repo. It can also be
+// written as a list of plugins.
+type OrgPlugins struct {
+	// ExcludedRepos are the repos of org, without the org, for which the
+	// plugins are not enabled. It is only valid for an org.
+	ExcludedRepos []string `json:"excluded_repos,omitempty"`
+	// Plugins are the enabled plugins.
+	Plugins []PluginEntry `json:"plugins,omitempty"`
+}
+
+// UnmarshalJSON accepts a list of plugins too.
+func (p *OrgPlugins) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		p.ExcludedRepos = nil
+		return json.Unmarshal(b, &p.Plugins)
+	}
+
+	type orgPlugins OrgPlugins
+	return json.Unmarshal(b, (*orgPlugins)(p))
+}
+
+// Names returns the names of plugins.
+func (p OrgPlugins) Names() []string {
+	r := make([]string, 0, len(p.Plugins))
+	for _, item := range p.Plugins {
+		r = append(r, item.Name)
+	}
+	return r
+}
+
+func (p OrgPlugins) excludes(repo string) bool {
+	for _, r := range p.ExcludedRepos {
+		if r == repo {
+			return true
+		}
+	}
+	return false
+}
+
+// PluginEntry is a plugin enabled for an org or a repo. It can also be
+// written as the plugin name.
+type PluginEntry struct {
+	Name string `json:"name"`
+	// Branches are the globs of target branches for which the plugin is
+	// enabled. The plugin is enabled for all the branches if empty. The
+	// events without a branch, such as the issue events, are not affected.
+	Branches []string `json:"branches,omitempty"`
+}
+
+// UnmarshalJSON accepts the plugin name too.
+func (p *PluginEntry) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '"' {
+		p.Branches = nil
+		return json.Unmarshal(b, &p.Name)
+	}
+
+	type pluginEntry PluginEntry
+	return json.Unmarshal(b, (*pluginEntry)(p))
+}
+
+func (p PluginEntry) enabledFor(branch string) bool {
+	return len(p.Branches) == 0 || branch == "" || matchAnyGlob(p.Branches, branch)
+}
+
+// BotEvents configures how to handle the events whose actor is the bot
+// which the hook runs as, or one of the other bots.
+type BotEvents struct {
+	// Policy is one of "deliver", "skip" and "tag". "skip" drops the
+	// events before any plugin handles them. "tag" delivers them with the
+	// X-Hook-Bot-Event header for external plugins and a log field.
+	// Defaults to "deliver", which handles them as other events.
+	Policy string `json:"policy,omitempty"`
+	// OtherBots are the logins of other bots, whose events are handled
+	// by Policy too.
+	OtherBots []string `json:"other_bots,omitempty"`
+}
+
 // ExternalPlugin holds configuration for registering an external
 // plugin in prow.
 type ExternalPlugin struct {
@@ -64,6 +162,90 @@ type ExternalPlugin struct {
 	// server to the external plugin. If no events are specified,
 	// everything is sent.
 	Events []string `json:"events,omitempty"`
+	// Timeout is the timeout of each attempt to deliver an event to
+	// the external plugin. Defaults to 10s.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// MaxRetries is the maximum number of attempts to deliver an event
+	// to the external plugin. Defaults to 5.
+	MaxRetries int `json:"max_retries,omitempty"`
+	// HMACSecretFile is the path to the file containing the secret shared
+	// with the external plugin. If set, the events will be signed with it
+	// so that the external plugin can verify they are sent by the hook.
+	HMACSecretFile string `json:"hmac_secret_file,omitempty"`
+	// CircuitBreakerThreshold is the number of consecutive failed deliveries
+	// after which the endpoint is regarded as unhealthy and no more events
+	// are delivered to it until the cooldown passes. Defaults to 5.
+	CircuitBreakerThreshold int `json:"circuit_breaker_threshold,omitempty"`
+	// CircuitBreakerCooldown is how long to wait before probing the unhealthy
+	// endpoint with an event again. Defaults to 1m.
+	CircuitBreakerCooldown *metav1.Duration `json:"circuit_breaker_cooldown,omitempty"`
+	// Delivery is how the events are delivered to the external plugin. It is
+	// either "push", which posts the events to Endpoint, or "stream", which
+	// lets the external plugin pull the events from the event stream of hook.
+	// The external plugin delivered by stream must set HMACSecretFile, whose
+	// content is the token to subscribe the stream. Defaults to "push".
+	Delivery string `json:"delivery,omitempty"`
+	// AllowedActions are the types of action which the hook executes when
+	// the external plugin replies to an event with them, such as "add_label"
+	// and "comment". No action is executed if empty.
+	AllowedActions []string `json:"allowed_actions,omitempty"`
+	// Filter holds the optional conditions an event must satisfy
+	// besides its type to be sent to the external plugin.
+	Filter *ExternalPluginFilter `json:"filter,omitempty"`
+}
+
+func (p ExternalPlugin) isStream() bool {
+	return p.Delivery == DeliveryStream
+}
+
+func (p ExternalPlugin) needEvent(eventType string) bool {
+	if len(p.Events) == 0 {
+		return true
+	}
+
+	for _, et := range p.Events {
+		if et == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (p ExternalPlugin) validate() error {
+	switch p.Delivery {
+	case "", DeliveryPush:
+	case DeliveryStream:
+		if p.HMACSecretFile == "" {
+			return fmt.Errorf("hmac_secret_file must be set to deliver by stream")
+		}
+	default:
+		return fmt.Errorf("unknown delivery: %s", p.Delivery)
+	}
+
+	known := sets.NewString(ActionAddLabel, ActionRemoveLabel, ActionComment, ActionAssign, ActionUnassign, ActionMerge)
+	for _, a := range p.AllowedActions {
+		if !known.Has(a) {
+			return fmt.Errorf("unknown action: %s", a)
+		}
+	}
+
+	if p.Filter != nil {
+		return p.Filter.Validate()
+	}
+	return nil
+}
+
+// streamPlugin returns the external plugin which is delivered by stream and
+// has the name.
+func (c *Configurations) streamPlugin(name string) *ExternalPlugin {
+	for _, eps := range c.ExternalPlugins {
+		for i := range eps {
+			if p := &eps[i]; p.Name == name && p.isStream() {
+				return p
+			}
+		}
+	}
+	return nil
 }
 
 func (c *Configurations) GetPluginConfig(name string) PluginConfig {
@@ -98,12 +280,14 @@ func (c *Configurations) SkipCollaborators(org, repo string) bool {
 	return false
 }
 
-// EnabledReposForPlugin returns the orgs and repos that have enabled the passed plugin.
-func (c *Configurations) EnabledReposForPlugin(plugin string) (orgs, repos []string) {
+// EnabledReposForPlugin returns the orgs and repos that have enabled the passed plugin,
+// and the repos excluded from each of the orgs.
+func (c *Configurations) EnabledReposForPlugin(plugin string) (orgs, repos []string, orgExceptions map[string]sets.String) {
+	orgExceptions = map[string]sets.String{}
 	for repo, plugins := range c.Plugins {
 		found := false
-		for _, candidate := range plugins {
-			if candidate == plugin {
+		for _, candidate := range plugins.Plugins {
+			if candidate.Name == plugin {
 				found = true
 				break
 			}
@@ -113,17 +297,74 @@ func (c *Configurations) EnabledReposForPlugin(plugin string) (orgs, repos []str
 				repos = append(repos, repo)
 			} else {
 				orgs = append(orgs, repo)
+				orgExceptions[repo] = sets.NewString()
+				for _, r := range plugins.ExcludedRepos {
+					orgExceptions[repo].Insert(fmt.Sprintf("%s/%s", repo, r))
+				}
 			}
 		}
 	}
 	return
 }
 
+// PluginsFor returns the plugins enabled for the branch of repo. The plugins
+// enabled for the org are included unless the repo is excluded. The branch
+// is empty if the event is not on a branch.
+func (c *Configurations) PluginsFor(org, repo, branch string) []string {
+	var r []string
+	add := func(ps []PluginEntry) {
+		for _, p := range ps {
+			if p.enabledFor(branch) {
+				r = append(r, p.Name)
+			}
+		}
+	}
+
+	if v, ok := c.Plugins[org]; ok && !v.excludes(repo) {
+		add(v.Plugins)
+	}
+	add(c.Plugins[fmt.Sprintf("%s/%s", org, repo)].Plugins)
+
+	return r
+}
+
 func (c *Configurations) Validate() error {
 	if len(c.Plugins) == 0 {
 		logrus.Warn("no plugins specified-- check syntax?")
 	}
 
+	for repo, ps := range c.Plugins {
+		if len(ps.ExcludedRepos) > 0 && strings.Contains(repo, "/") {
+			return fmt.Errorf("%s: excluded_repos is only valid for an org", repo)
+		}
+
+		for _, p := range ps.Plugins {
+			if p.Name == "" {
+				return fmt.Errorf("%s: plugin name must be set", repo)
+			}
+
+			for _, b := range p.Branches {
+				if _, err := globToRegexp(b); err != nil {
+					return fmt.Errorf("%s: invalid branch %q of plugin %s: %v", repo, b, p.Name, err)
+				}
+			}
+		}
+	}
+
+	switch c.BotEvents.Policy {
+	case "", BotEventsDeliver, BotEventsSkip, BotEventsTag:
+	default:
+		return fmt.Errorf("unknown policy of bot_events: %s", c.BotEvents.Policy)
+	}
+
+	for _, eps := range c.ExternalPlugins {
+		for _, p := range eps {
+			if err := p.validate(); err != nil {
+				return fmt.Errorf("external plugin %s: %v", p.Name, err)
+			}
+		}
+	}
+
 	for _, p := range c.pluginConfigs {
 		p.SetDefault()
 
@@ -135,12 +376,7 @@ func (c *Configurations) Validate() error {
 	return nil
 }
 
-func load(path string, c *Configurations) error {
-	b, err := ioutil.ReadFile(path)
-	if err != nil {
-		return err
-	}
-
+func load(b []byte, c *Configurations) error {
 	if err := yaml.Unmarshal(b, c); err != nil {
 		return err
 	}
@@ -0,0 +1,90 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/opensourceways/yabot/gitee/hook"
+)
+
+// DeadLetter is an event which could not be delivered to an external plugin.
+type DeadLetter struct {
+	Plugin    string          `json:"plugin"`
+	Endpoint  string          `json:"endpoint"`
+	EventType string          `json:"event_type"`
+	EventGUID string          `json:"event_guid"`
+	Header    http.Header     `json:"header"`
+	Payload   json.RawMessage `json:"payload"`
+	FailedAt  time.Time       `json:"failed_at"`
+	Error     string          `json:"error"`
+}
+
+// DeadLetterStore persists the dead letters in a directory, one file for each.
+type DeadLetterStore struct {
+	dir string
+}
+
+func NewDeadLetterStore(dir string) *DeadLetterStore {
+	return &DeadLetterStore{dir: dir}
+}
+
+// Save writes the dead letter to the store and returns the path of the file.
+func (s *DeadLetterStore) Save(dl *DeadLetter) (string, error) {
+	b, err := json.MarshalIndent(dl, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%d-%s-%s.json", dl.FailedAt.UnixNano(), hook.FileNameSafe(dl.Plugin), hook.FileNameSafe(dl.EventGUID))
+	path := filepath.Join(s.dir, name)
+	return path, ioutil.WriteFile(path, b, 0644)
+}
+
+// List returns the paths of all the dead letters in the store, the oldest first.
+func (s *DeadLetterStore) List() ([]string, error) {
+	fs, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var r []string
+	for _, f := range fs {
+		if !f.IsDir() && filepath.Ext(f.Name()) == ".json" {
+			r = append(r, filepath.Join(s.dir, f.Name()))
+		}
+	}
+	sort.Strings(r)
+	return r, nil
+}
+
+// Load reads the dead letter stored at path.
+func (s *DeadLetterStore) Load(path string) (*DeadLetter, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var dl DeadLetter
+	if err := json.Unmarshal(b, &dl); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	return &dl, nil
+}
+
+// Remove deletes the dead letter stored at path.
+func (s *DeadLetterStore) Remove(path string) error {
+	return os.Remove(path)
+}
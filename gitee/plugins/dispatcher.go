@@ -1,10 +1,8 @@
 package plugins
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"strings"
 	"sync"
@@ -12,18 +10,79 @@ import (
 
 	"gitee.com/openeuler/go-gitee/gitee"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/github"
 
 	"github.com/opensourceways/yabot/gitee/hook"
 )
 
-func NewDispatcher(c *ConfigAgent, ps Plugins) hook.Dispatcher {
-	return &dispatcher{c: c, ps: ps.(*plugins)}
+// dispatcherClient is the Gitee client used by dispatcher.
+type dispatcherClient interface {
+	BotName() (string, error)
+	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+	GetGiteePullRequest(org, repo string, number int) (gitee.PullRequest, error)
+
+	AddPRLabel(org, repo string, number int, label string) error
+	RemovePRLabel(org, repo string, number int, label string) error
+	CreatePRComment(org, repo string, number int, comment string) error
+	AssignPR(owner, repo string, number int, logins []string) error
+	UnassignPR(owner, repo string, number int, logins []string) error
+	MergePR(owner, repo string, number int, opt gitee.PullRequestMergePutParam) error
+
+	AddIssueLabel(org, repo, number, label string) error
+	RemoveIssueLabel(org, repo, number, label string) error
+	CreateGiteeIssueComment(org, repo string, number string, comment string) error
+	AssignGiteeIssue(org, repo string, number string, login string) error
+	UnassignGiteeIssue(org, repo string, number string, login string) error
+}
+
+// DispatcherOptions holds the optional parts of dispatcher. Each of them is
+// disabled if it is nil.
+type DispatcherOptions struct {
+	// DeadLetters saves the events which can't be delivered to external
+	// plugins.
+	DeadLetters *DeadLetterStore
+	// Health tracks the health of external plugin endpoints.
+	Health *ExternalPluginHealth
+	// Stream publishes the events for external plugins delivered by stream.
+	Stream *EventStream
+	// RepoConfigs holds the configs in repos, by which the plugins for
+	// a repo are tuned.
+	RepoConfigs *RepoConfigCache
+	// Mergeable refuses the merge actions of external plugins if it says so.
+	Mergeable MergeChecker
+}
+
+// NewDispatcher returns a dispatcher with the optional parts in opts.
+func NewDispatcher(c *ConfigAgent, ps Plugins, gc dispatcherClient, opts DispatcherOptions) hook.Dispatcher {
+	return &dispatcher{
+		c:           c,
+		ps:          ps.(*plugins),
+		gc:          gc,
+		dls:         opts.DeadLetters,
+		health:      opts.Health,
+		stream:      opts.Stream,
+		repoConfigs: opts.RepoConfigs,
+		mergeable:   opts.Mergeable,
+	}
 }
 
 type dispatcher struct {
-	c  *ConfigAgent
-	ps *plugins
+	c      *ConfigAgent
+	ps     *plugins
+	gc     dispatcherClient
+	dls    *DeadLetterStore
+	health *ExternalPluginHealth
+	stream *EventStream
+	// repoConfigs holds the configs in repos. It is nil if the repos
+	// can't configure the plugins.
+	repoConfigs *RepoConfigCache
+	// mergeable checks whether the pull request can be merged by the
+	// actions of external plugins. It can be nil.
+	mergeable MergeChecker
+
+	botMut sync.Mutex
+	bot    string
 
 	// ec is an http client used for dispatching events
 	// to external plugin services.
@@ -33,7 +92,7 @@ type dispatcher struct {
 }
 
 func (d *dispatcher) issueHandlers(owner, repo string) map[string]IssueHandler {
-	ps := d.getPlugins(owner, repo)
+	ps := d.getPlugins(owner, repo, "")
 	hs := d.ps.issueHandlers
 
 	r := map[string]IssueHandler{}
@@ -45,8 +104,8 @@ func (d *dispatcher) issueHandlers(owner, repo string) map[string]IssueHandler {
 	return r
 }
 
-func (d *dispatcher) pullRequestHandlers(owner, repo string) map[string]PullRequestHandler {
-	ps := d.getPlugins(owner, repo)
+func (d *dispatcher) pullRequestHandlers(owner, repo, branch string) map[string]PullRequestHandler {
+	ps := d.getPlugins(owner, repo, branch)
 	hs := d.ps.pullRequestHandlers
 
 	r := map[string]PullRequestHandler{}
@@ -58,8 +117,8 @@ func (d *dispatcher) pullRequestHandlers(owner, repo string) map[string]PullRequ
 	return r
 }
 
-func (d *dispatcher) pushEventHandlers(owner, repo string) map[string]PushEventHandler {
-	ps := d.getPlugins(owner, repo)
+func (d *dispatcher) pushEventHandlers(owner, repo, branch string) map[string]PushEventHandler {
+	ps := d.getPlugins(owner, repo, branch)
 	hs := d.ps.pushEventHandlers
 
 	r := map[string]PushEventHandler{}
@@ -71,8 +130,8 @@ func (d *dispatcher) pushEventHandlers(owner, repo string) map[string]PushEventH
 	return r
 }
 
-func (d *dispatcher) noteEventHandlers(owner, repo string) map[string]NoteEventHandler {
-	ps := d.getPlugins(owner, repo)
+func (d *dispatcher) noteEventHandlers(owner, repo, branch string) map[string]NoteEventHandler {
+	ps := d.getPlugins(owner, repo, branch)
 	hs := d.ps.noteEventHandlers
 
 	r := map[string]NoteEventHandler{}
@@ -84,13 +143,14 @@ func (d *dispatcher) noteEventHandlers(owner, repo string) map[string]NoteEventH
 	return r
 }
 
-func (d *dispatcher) getPlugins(owner, repo string) []string {
-	var plugins []string
+// getPlugins returns the plugins enabled for the branch of repo. The branch
+// is empty if the event is not on a branch.
+func (d *dispatcher) getPlugins(owner, repo, branch string) []string {
+	plugins := d.c.Config().PluginsFor(owner, repo, branch)
 
-	c := d.c.Config()
-	fullName := fmt.Sprintf("%s/%s", owner, repo)
-	plugins = append(plugins, c.Plugins[owner]...)
-	plugins = append(plugins, c.Plugins[fullName]...)
+	if disabled := d.repoConfigs.disabledPlugins(owner, repo); len(disabled) > 0 {
+		plugins = sets.NewString(plugins...).Delete(disabled...).List()
+	}
 
 	return plugins
 }
@@ -108,6 +168,8 @@ func (d *dispatcher) Dispatch(eventType, eventGUID string, payload []byte, h htt
 	)
 
 	var srcRepo string
+	var ei *eventInfo
+	var handle func(*logrus.Entry)
 	switch eventType {
 	case "Note Hook":
 		var e gitee.NoteEvent
@@ -115,8 +177,8 @@ func (d *dispatcher) Dispatch(eventType, eventGUID string, payload []byte, h htt
 			return err
 		}
 		srcRepo = e.Repository.FullName
-		d.wg.Add(1)
-		go d.handleNoteEvent(&e, l)
+		ei = d.noteEventInfo(&e)
+		handle = func(l *logrus.Entry) { d.handleNoteEvent(&e, l) }
 
 	case "Issue Hook":
 		var ie gitee.IssueEvent
@@ -124,8 +186,8 @@ func (d *dispatcher) Dispatch(eventType, eventGUID string, payload []byte, h htt
 			return err
 		}
 		srcRepo = ie.Repository.FullName
-		d.wg.Add(1)
-		go d.handleIssueEvent(&ie, l)
+		ei = issueEventInfo(&ie)
+		handle = func(l *logrus.Entry) { d.handleIssueEvent(&ie, l) }
 
 	case "Merge Request Hook":
 		var pr gitee.PullRequestEvent
@@ -133,8 +195,8 @@ func (d *dispatcher) Dispatch(eventType, eventGUID string, payload []byte, h htt
 			return err
 		}
 		srcRepo = pr.Repository.FullName
-		d.wg.Add(1)
-		go d.handlePullRequestEvent(&pr, l)
+		ei = d.pullRequestEventInfo(&pr)
+		handle = func(l *logrus.Entry) { d.handlePullRequestEvent(&pr, l) }
 
 	case "Push Hook":
 		var pe gitee.PushEvent
@@ -142,96 +204,208 @@ func (d *dispatcher) Dispatch(eventType, eventGUID string, payload []byte, h htt
 			return err
 		}
 		srcRepo = pe.Repository.FullName
-		d.wg.Add(1)
-		go d.handlePushEvent(&pe, l)
+		ei = pushEventInfo(&pe)
+		d.repoConfigs.handlePushEvent(&pe)
+		handle = func(l *logrus.Entry) { d.handlePushEvent(&pe, l) }
 
 	default:
 		l.Debug("Ignoring unhandled event type")
 	}
-	//dispatcher hook event only to external plugins that require this event
-	if eps := d.needDispatchExternalPlugins(eventType, srcRepo); len(eps) > 0 {
-		go d.dispatchExternal(l, eps, payload, h)
+
+	if ei != nil && d.isBotEvent(l, ei.actor) {
+		switch d.c.Config().BotEvents.Policy {
+		case BotEventsSkip:
+			l.WithField("actor", ei.actor).Info("Skipping the event caused by bot.")
+			return nil
+
+		case BotEventsTag:
+			l = l.WithField("bot-event", true)
+			h = h.Clone()
+			h.Set(BotEventHeader, "true")
+		}
+	}
+
+	if handle != nil {
+		d.wg.Add(1)
+		go handle(l)
 	}
+
+	//dispatcher hook event only to external plugins that require this event
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		if eps := d.needDispatchExternalPlugins(l, eventType, srcRepo, ei); len(eps) > 0 {
+			d.dispatchExternal(l, eps, ei, eventType, eventGUID, payload, h)
+		}
+	}()
 	return nil
 }
 
-func (d *dispatcher) needDispatchExternalPlugins(eventType, srcRepo string) []ExternalPlugin {
+// isBotEvent reports whether the actor of event is the bot which the hook runs
+// as or one of the other bots. It is always false if the bot events are delivered
+// as usual, to avoid resolving the name of bot.
+func (d *dispatcher) isBotEvent(l *logrus.Entry, actor string) bool {
+	cfg := d.c.Config().BotEvents
+	if actor == "" || cfg.Policy == "" || cfg.Policy == BotEventsDeliver {
+		return false
+	}
+
+	for _, b := range cfg.OtherBots {
+		if strings.EqualFold(b, actor) {
+			return true
+		}
+	}
+
+	bot, err := d.botName()
+	if err != nil {
+		l.WithError(err).Warn("Failed to get the name of bot.")
+		return false
+	}
+	return strings.EqualFold(bot, actor)
+}
+
+// botName returns the login of bot. It is resolved only once if succeeded.
+func (d *dispatcher) botName() (string, error) {
+	d.botMut.Lock()
+	defer d.botMut.Unlock()
+
+	if d.bot == "" {
+		if d.gc == nil {
+			return "", fmt.Errorf("no gitee client to get the name of bot")
+		}
+
+		name, err := d.gc.BotName()
+		if err != nil {
+			return "", err
+		}
+		d.bot = name
+	}
+	return d.bot, nil
+}
+
+func (d *dispatcher) needDispatchExternalPlugins(l *logrus.Entry, eventType, srcRepo string, ei *eventInfo) []ExternalPlugin {
 	var matching []ExternalPlugin
-	srcOrg := strings.Split(srcRepo, "/")[0]
-	for repo, ep := range d.c.Config().ExternalPlugins {
+	c := d.c.Config()
+	v := strings.Split(srcRepo, "/")
+	srcOrg := v[0]
+
+	// The external plugins enabled for the org can be disabled by the repo.
+	disabled := sets.NewString()
+	if len(v) == 2 && len(c.ExternalPlugins[srcOrg]) > 0 {
+		disabled.Insert(d.repoConfigs.disabledPlugins(srcOrg, v[1])...)
+	}
+
+	for repo, ep := range c.ExternalPlugins {
 		if repo != srcRepo && repo != srcOrg {
 			continue
 		}
 		for _, p := range ep {
-			if len(p.Events) == 0 {
+			if repo == srcOrg && disabled.Has(p.Name) {
+				continue
+			}
+			if !p.needEvent(eventType) {
+				continue
+			}
+
+			b, err := p.Filter.match(ei)
+			if err != nil {
+				l.WithError(err).WithField("external-plugin", p.Name).Error("Error filtering event for external plugin.")
+				continue
+			}
+			if b {
 				matching = append(matching, p)
-			} else {
-				for _, et := range p.Events {
-					if et != eventType {
-						continue
-					}
-					matching = append(matching, p)
-					break
-				}
 			}
 		}
 	}
 	return matching
 }
 
-func (d *dispatcher) dispatchExternal(l *logrus.Entry, externalPlugins []ExternalPlugin, payload []byte, h http.Header) {
+func (d *dispatcher) dispatchExternal(l *logrus.Entry, externalPlugins []ExternalPlugin, ei *eventInfo, eventType, eventGUID string, payload []byte, h http.Header) {
+	h = h.Clone()
 	h.Set("User-Agent", "ProwHook")
+	stripCredentials(h)
+
+	var streamPlugins []string
 	for _, p := range externalPlugins {
+		if p.isStream() {
+			streamPlugins = append(streamPlugins, p.Name)
+			continue
+		}
+
 		d.wg.Add(1)
 		go func(p ExternalPlugin) {
 			defer d.wg.Done()
-			if err := d.dispatch(p.Endpoint, payload, h); err != nil {
-				l.WithError(err).WithField("external-plugin", p.Name).Error("Error dispatching event to external plugin.")
-			} else {
-				l.WithField("external-plugin", p.Name).Info("Dispatched event to external plugin")
+
+			l := l.WithField("external-plugin", p.Name)
+			resp, err := d.dispatch(p, payload, h)
+			if err != nil {
+				l.WithError(err).Error("Error dispatching event to external plugin.")
+
+				d.saveDeadLetter(l, p, eventType, eventGUID, payload, h, err)
+				return
+			}
+			l.Info("Dispatched event to external plugin")
+
+			actions, err := parseActions(resp)
+			if err != nil {
+				l.WithError(err).Warn("Error parsing the actions replied by external plugin.")
+				return
+			}
+			if err := d.executeActions(l, p, ei.actionTarget(), actions); err != nil {
+				l.WithError(err).Error("Error executing the actions replied by external plugin.")
 			}
 		}(p)
 	}
-}
 
-// dispatch creates a new request using the provided payload and headers
-// and dispatches the request to the provided endpoint.
-func (d *dispatcher) dispatch(endpoint string, payload []byte, h http.Header) error {
-	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(payload))
-	if err != nil {
-		return err
-	}
-	req.Header = h
-	resp, err := d.do(req)
-	if err != nil {
-		return err
+	if len(streamPlugins) > 0 {
+		if d.stream == nil {
+			l.WithField("external-plugins", streamPlugins).Error("No event stream for the external plugins.")
+			return
+		}
+
+		d.stream.publish(streamPlugins, eventType, eventGUID, payload, h)
+		l.WithField("external-plugins", streamPlugins).Info("Published event to the event stream.")
 	}
-	defer resp.Body.Close()
-	rb, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+}
+
+// dispatch delivers the event to the external plugin unless the circuit
+// breaker of its endpoint is open. It returns the body of response.
+func (d *dispatcher) dispatch(p ExternalPlugin, payload []byte, h http.Header) ([]byte, error) {
+	if d.health == nil {
+		return deliver(&d.ec, p, payload, h)
 	}
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("response has status %q and body %q", resp.Status, string(rb))
+
+	if !d.health.allow(p) {
+		return nil, fmt.Errorf("the circuit breaker of endpoint %s is open", p.Endpoint)
 	}
-	return nil
+
+	start := time.Now()
+	resp, err := deliver(&d.ec, p, payload, h)
+	d.health.record(p, time.Since(start), err)
+	return resp, err
 }
 
-func (d *dispatcher) do(req *http.Request) (*http.Response, error) {
-	var resp *http.Response
-	var err error
-	backoff := 100 * time.Millisecond
-	maxRetries := 5
+func (d *dispatcher) saveDeadLetter(l *logrus.Entry, p ExternalPlugin, eventType, eventGUID string, payload []byte, h http.Header, err error) {
+	if d.dls == nil {
+		return
+	}
 
-	for retries := 0; retries < maxRetries; retries++ {
-		resp, err = d.ec.Do(req)
-		if err == nil {
-			break
-		}
-		time.Sleep(backoff)
-		backoff *= 2
+	dl := &DeadLetter{
+		Plugin:    p.Name,
+		Endpoint:  p.Endpoint,
+		EventType: eventType,
+		EventGUID: eventGUID,
+		Header:    h,
+		Payload:   payload,
+		FailedAt:  time.Now(),
+		Error:     err.Error(),
+	}
+	if path, err := d.dls.Save(dl); err != nil {
+		l.WithError(err).Error("Error saving the dead letter.")
+	} else {
+		l.WithField("path", path).Info("Saved the dead letter.")
 	}
-	return resp, err
 }
 
 func (d *dispatcher) handlePullRequestEvent(pr *gitee.PullRequestEvent, l *logrus.Entry) {
@@ -246,7 +420,7 @@ func (d *dispatcher) handlePullRequestEvent(pr *gitee.PullRequestEvent, l *logru
 	})
 	l.Infof("Pull request %s.", *pr.Action)
 
-	for p, h := range d.pullRequestHandlers(pr.Repository.Namespace, pr.Repository.Path) {
+	for p, h := range d.pullRequestHandlers(pr.Repository.Namespace, pr.Repository.Path, pr.PullRequest.Base.Ref) {
 		d.wg.Add(1)
 
 		go func(p string, h PullRequestHandler) {
@@ -295,7 +469,7 @@ func (d *dispatcher) handlePushEvent(pe *gitee.PushEvent, l *logrus.Entry) {
 	})
 	l.Info("Push event.")
 
-	for p, h := range d.pushEventHandlers(pe.Repository.Owner.Name, pe.Repository.Path) {
+	for p, h := range d.pushEventHandlers(pe.Repository.Owner.Name, pe.Repository.Path, strings.TrimPrefix(strOf(pe.Ref), "refs/heads/")) {
 		d.wg.Add(1)
 
 		go func(p string, h PushEventHandler) {
@@ -312,9 +486,11 @@ func (d *dispatcher) handleNoteEvent(e *gitee.NoteEvent, l *logrus.Entry) {
 	defer d.wg.Done()
 
 	var n interface{}
+	var branch string
 	switch *(e.NoteableType) {
 	case "PullRequest":
 		n = e.PullRequest.Number
+		branch = e.PullRequest.Base.Ref
 	case "Issue":
 		n = e.Issue.Number
 	}
@@ -329,7 +505,7 @@ func (d *dispatcher) handleNoteEvent(e *gitee.NoteEvent, l *logrus.Entry) {
 	})
 	l.Infof("Note %s.", *e.Action)
 
-	for p, h := range d.noteEventHandlers(e.Repository.Namespace, e.Repository.Path) {
+	for p, h := range d.noteEventHandlers(e.Repository.Namespace, e.Repository.Path, branch) {
 		d.wg.Add(1)
 
 		go func(p string, h NoteEventHandler) {
@@ -0,0 +1,121 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/opensourceways/yabot/prow/pluginhelp/externalplugins"
+)
+
+const (
+	defaultExternalPluginTimeout    = 10 * time.Second
+	defaultExternalPluginMaxRetries = 5
+)
+
+// DeliverToExternalPlugin sends the event to the external plugin by the
+// same way as the dispatcher does. The actions which the external plugin
+// replies with are not executed.
+func DeliverToExternalPlugin(p ExternalPlugin, payload []byte, h http.Header) error {
+	_, err := deliver(http.DefaultClient, p, payload, h)
+	return err
+}
+
+// deliver posts the payload to the endpoint of external plugin and returns
+// the body of response. It retries with an exponential backoff on transport
+// errors and retryable status codes.
+func deliver(c *http.Client, p ExternalPlugin, payload []byte, h http.Header) ([]byte, error) {
+	var err error
+	var retry bool
+	var resp []byte
+	backoff := 100 * time.Millisecond
+
+	for retries := 0; retries < p.maxRetries(); retries++ {
+		if retries > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		if resp, retry, err = deliverOnce(c, p, payload, h); err == nil || !retry {
+			break
+		}
+	}
+	return resp, err
+}
+
+// deliverOnce makes one attempt to deliver the payload. It returns the body
+// of response and whether the attempt is worth retrying when failed.
+func deliverOnce(c *http.Client, p ExternalPlugin, payload []byte, h http.Header) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout())
+	defer cancel()
+
+	// The body of request will be consumed by each attempt, so it has to be rebuilt.
+	req, err := http.NewRequest(http.MethodPost, p.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header = h.Clone()
+	stripCredentials(req.Header)
+
+	if p.HMACSecretFile != "" {
+		secret, err := ioutil.ReadFile(p.HMACSecretFile)
+		if err != nil {
+			return nil, false, fmt.Errorf("read hmac secret: %v", err)
+		}
+		externalplugins.SignRequest(req.Header, []byte(strings.TrimSpace(string(secret))), payload)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	rb, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, isRetryableStatus(resp.StatusCode), fmt.Errorf("response has status %q and body %q", resp.Status, string(rb))
+	}
+	return rb, false, nil
+}
+
+// stripCredentials removes the credentials sent by Gitee, which must not
+// be leaked to external plugins, and any signature not made by the hook.
+func stripCredentials(h http.Header) {
+	ks := []string{
+		"X-Gitee-Token", "Authorization", "Cookie",
+		externalplugins.SignatureHeader, externalplugins.TimestampHeader,
+	}
+	for _, k := range ks {
+		h.Del(k)
+	}
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return code >= 500
+}
+
+func (p ExternalPlugin) timeout() time.Duration {
+	if p.Timeout != nil && p.Timeout.Duration > 0 {
+		return p.Timeout.Duration
+	}
+	return defaultExternalPluginTimeout
+}
+
+func (p ExternalPlugin) maxRetries() int {
+	if p.MaxRetries > 0 {
+		return p.MaxRetries
+	}
+	return defaultExternalPluginMaxRetries
+}
@@ -0,0 +1,308 @@
+package plugins
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gitee.com/openeuler/go-gitee/gitee"
+)
+
+// ExternalPluginFilter holds the optional conditions, besides the event type,
+// which an event must satisfy to be sent to the external plugin. Each condition
+// only applies to the events which have the corresponding attribute. For example,
+// Branches doesn't filter the issue events.
+type ExternalPluginFilter struct {
+	// Actions are the actions of pull request, issue or note event, such
+	// as "open", "update" or "comment". The action description of pull
+	// request event, such as "source_branch_changed", is also matched.
+	Actions []string `json:"actions,omitempty"`
+	// Branches are the globs of target branch of pull request, or the
+	// branch of push event.
+	Branches []string `json:"branches,omitempty"`
+	// CommentRegexp is the regexp which the body of comment must match,
+	// such as "(?m)^/retest\\s*$".
+	CommentRegexp string `json:"comment_regexp,omitempty"`
+	// NoteableTypes are the types of object the comment is made on, such
+	// as "PullRequest", "Issue" or "Commit".
+	NoteableTypes []string `json:"noteable_types,omitempty"`
+	// Paths are the globs of files, one of which must be changed by the pull
+	// request or push event. "**" matches any number of directories.
+	Paths []string `json:"paths,omitempty"`
+
+	// The compiled CommentRegexp, Branches and Paths, which are set by
+	// Validate.
+	commentRe *regexp.Regexp
+	branchRes []*regexp.Regexp
+	pathRes   []*regexp.Regexp
+}
+
+// Validate checks the regexp and globs of filter, and keeps the compiled
+// ones to match the events.
+func (f *ExternalPluginFilter) Validate() error {
+	var err error
+	if f.CommentRegexp != "" {
+		if f.commentRe, err = regexp.Compile(f.CommentRegexp); err != nil {
+			return fmt.Errorf("invalid comment_regexp: %v", err)
+		}
+	}
+
+	if f.branchRes, err = compileGlobs(f.Branches); err != nil {
+		return err
+	}
+
+	f.pathRes, err = compileGlobs(f.Paths)
+	return err
+}
+
+func compileGlobs(globs []string) ([]*regexp.Regexp, error) {
+	r := make([]*regexp.Regexp, 0, len(globs))
+	for _, v := range globs {
+		re, err := globToRegexp(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", v, err)
+		}
+		r = append(r, re)
+	}
+	return r, nil
+}
+
+// eventInfo holds the attributes of an event which the external plugin
+// filters are evaluated against. An empty attribute means the event does
+// not have it.
+type eventInfo struct {
+	actions      []string
+	branch       string
+	comment      string
+	noteableType string
+
+	// changedFiles returns the files changed by the event. It is nil if the
+	// event doesn't change files.
+	changedFiles func() ([]string, error)
+
+	// target is the pull request or issue of the event. It is nil if the
+	// event is not on any of them.
+	target *actionTarget
+
+	// actor is the login of user who caused the event.
+	actor string
+}
+
+func (ei *eventInfo) actionTarget() *actionTarget {
+	if ei == nil {
+		return nil
+	}
+	return ei.target
+}
+
+func (d *dispatcher) noteEventInfo(e *gitee.NoteEvent) *eventInfo {
+	ei := &eventInfo{
+		actions:      nonEmpty(strOf(e.Action)),
+		comment:      e.Comment.Body,
+		noteableType: strOf(e.NoteableType),
+		actor:        e.Comment.User.Login,
+	}
+
+	org, repo := e.Repository.Namespace, e.Repository.Path
+	switch ei.noteableType {
+	case "PullRequest":
+		pr := e.PullRequest
+		ei.branch = pr.Base.Ref
+		ei.changedFiles = d.prChangedFiles(org, repo, int(pr.Number))
+		ei.target = &actionTarget{org: org, repo: repo, prNumber: int(pr.Number)}
+
+	case "Issue":
+		ei.target = &actionTarget{org: org, repo: repo, issueNumber: e.Issue.Number}
+	}
+	return ei
+}
+
+func issueEventInfo(e *gitee.IssueEvent) *eventInfo {
+	return &eventInfo{
+		actions: nonEmpty(strOf(e.Action)),
+		actor:   firstLogin(e.Sender, e.User),
+		target: &actionTarget{
+			org:         e.Repository.Namespace,
+			repo:        e.Repository.Path,
+			issueNumber: e.Issue.Number,
+		},
+	}
+}
+
+func (d *dispatcher) pullRequestEventInfo(e *gitee.PullRequestEvent) *eventInfo {
+	pr := e.PullRequest
+	org, repo := e.Repository.Namespace, e.Repository.Path
+	return &eventInfo{
+		actions:      nonEmpty(strOf(e.Action), strOf(e.ActionDesc)),
+		branch:       pr.Base.Ref,
+		changedFiles: d.prChangedFiles(org, repo, int(pr.Number)),
+		target:       &actionTarget{org: org, repo: repo, prNumber: int(pr.Number)},
+		actor:        firstLogin(e.Sender, e.UpdatedBy),
+	}
+}
+
+func pushEventInfo(e *gitee.PushEvent) *eventInfo {
+	var files []string
+	for _, c := range e.Commits {
+		files = append(files, c.Added...)
+		files = append(files, c.Modified...)
+		files = append(files, c.Removed...)
+	}
+
+	return &eventInfo{
+		branch:       strings.TrimPrefix(strOf(e.Ref), "refs/heads/"),
+		changedFiles: func() ([]string, error) { return files, nil },
+		actor:        firstLogin(e.Sender, e.User),
+	}
+}
+
+// prChangedFiles returns a function which lists the files changed by the pull
+// request. The files are fetched once at most, no matter how many times the
+// function is called.
+func (d *dispatcher) prChangedFiles(org, repo string, number int) func() ([]string, error) {
+	var once sync.Once
+	var files []string
+	var err error
+
+	return func() ([]string, error) {
+		once.Do(func() {
+			if d.gc == nil {
+				err = fmt.Errorf("no gitee client to list the files changed by pull request")
+				return
+			}
+
+			changes, err1 := d.gc.GetPullRequestChanges(org, repo, number)
+			if err1 != nil {
+				err = err1
+				return
+			}
+			for _, c := range changes {
+				files = append(files, c.Filename)
+			}
+		})
+		return files, err
+	}
+}
+
+// match evaluates the filter against the event. The filter must have been
+// validated.
+func (f *ExternalPluginFilter) match(ei *eventInfo) (bool, error) {
+	if f == nil || ei == nil {
+		return true, nil
+	}
+
+	if len(f.Actions) > 0 && len(ei.actions) > 0 && !hasIntersection(f.Actions, ei.actions) {
+		return false, nil
+	}
+
+	if len(f.NoteableTypes) > 0 && ei.noteableType != "" && !hasIntersection(f.NoteableTypes, []string{ei.noteableType}) {
+		return false, nil
+	}
+
+	if len(f.Branches) > 0 && ei.branch != "" && !matchAnyRegexp(f.branchRes, ei.branch) {
+		return false, nil
+	}
+
+	if f.commentRe != nil && ei.noteableType != "" && !f.commentRe.MatchString(ei.comment) {
+		return false, nil
+	}
+
+	if len(f.Paths) > 0 && ei.changedFiles != nil {
+		files, err := ei.changedFiles()
+		if err != nil {
+			return false, err
+		}
+
+		for _, file := range files {
+			if matchAnyRegexp(f.pathRes, file) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func matchAnyRegexp(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyGlob(globs []string, s string) bool {
+	for _, g := range globs {
+		if re, err := globToRegexp(g); err == nil && re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// globToRegexp converts the glob to a regexp. "**" matches any characters,
+// "*" matches any characters except "/" and "?" matches one of them.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func hasIntersection(a, b []string) bool {
+	for _, i := range a {
+		for _, j := range b {
+			if i == j {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func nonEmpty(v ...string) []string {
+	r := make([]string, 0, len(v))
+	for _, s := range v {
+		if s != "" {
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+// firstLogin returns the login of the first user which is not nil.
+func firstLogin(users ...*gitee.UserHook) string {
+	for _, u := range users {
+		if u != nil && u.Login != "" {
+			return u.Login
+		}
+	}
+	return ""
+}
+
+func strOf(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
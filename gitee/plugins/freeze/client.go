@@ -0,0 +1,14 @@
+package freeze
+
+import (
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+
+	"github.com/opensourceways/yabot/gitee/gitee"
+)
+
+type giteeClient interface {
+	GetPullRequests(org, repo string, opts gitee.ListPullRequestOpt) ([]sdk.PullRequest, error)
+	AddPRLabel(owner, repo string, number int, label string) error
+	RemovePRLabel(owner, repo string, number int, label string) error
+	CreatePRComment(owner, repo string, number int, comment string) error
+}
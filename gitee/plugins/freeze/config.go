@@ -0,0 +1,126 @@
+package freeze
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/huaweicloud/golangsdk"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type configuration struct {
+	// Freeze is the calendar of freeze windows. A branch may be frozen by
+	// more than one window.
+	Freeze []pluginConfig `json:"freeze,omitempty"`
+}
+
+func (c *configuration) Validate() error {
+	if _, err := golangsdk.BuildRequestBody(c, ""); err != nil {
+		return err
+	}
+
+	for i := range c.Freeze {
+		if err := c.Freeze[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *configuration) SetDefault() {
+}
+
+// activeFreezes returns the windows which freeze the branch of repo at t.
+func (c *configuration) activeFreezes(org, repo, branch string, t time.Time) []*pluginConfig {
+	fullName := fmt.Sprintf("%s/%s", org, repo)
+
+	var r []*pluginConfig
+	for i := range c.Freeze {
+		item := &(c.Freeze[i])
+
+		s := sets.NewString(item.Repos...)
+		if !s.Has(fullName) && !s.Has(org) {
+			continue
+		}
+
+		if item.freezes(branch) && item.activeAt(t) {
+			r = append(r, item)
+		}
+	}
+	return r
+}
+
+type pluginConfig struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos" required:"true"`
+
+	// Branches are the globs of target branches to freeze. All the branches
+	// are frozen if empty.
+	Branches []string `json:"branches,omitempty"`
+
+	// Start is the time when the freeze starts, in RFC3339 format, such
+	// as "2020-09-01T00:00:00+08:00".
+	Start string `json:"start" required:"true"`
+
+	// End is the time when the freeze ends, in RFC3339 format.
+	End string `json:"end" required:"true"`
+
+	// ExemptLabel is the label with which a pull request is not frozen,
+	// such as the one added by the release managers for critical fixes.
+	ExemptLabel string `json:"exempt_label,omitempty"`
+
+	// ExemptUsers are the authors whose pull requests are not frozen.
+	ExemptUsers []string `json:"exempt_users,omitempty"`
+}
+
+func (p *pluginConfig) validate() error {
+	start, err := time.Parse(time.RFC3339, p.Start)
+	if err != nil {
+		return fmt.Errorf("invalid start of freeze: %v", err)
+	}
+
+	end, err := time.Parse(time.RFC3339, p.End)
+	if err != nil {
+		return fmt.Errorf("invalid end of freeze: %v", err)
+	}
+
+	if !start.Before(end) {
+		return fmt.Errorf("the freeze from %s to %s is empty", p.Start, p.End)
+	}
+
+	for _, glob := range p.Branches {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid branch %q of freeze: %v", glob, err)
+		}
+	}
+	return nil
+}
+
+func (p *pluginConfig) freezes(branch string) bool {
+	if len(p.Branches) == 0 {
+		return true
+	}
+
+	for _, glob := range p.Branches {
+		if ok, _ := path.Match(glob, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pluginConfig) activeAt(t time.Time) bool {
+	// The times have been validated.
+	start, _ := time.Parse(time.RFC3339, p.Start)
+	end, _ := time.Parse(time.RFC3339, p.End)
+
+	return !t.Before(start) && t.Before(end)
+}
+
+func (p *pluginConfig) exempts(author string, labels sets.String) bool {
+	if p.ExemptLabel != "" && labels.Has(p.ExemptLabel) {
+		return true
+	}
+	return sets.NewString(p.ExemptUsers...).Has(author)
+}
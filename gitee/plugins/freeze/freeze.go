@@ -0,0 +1,214 @@
+package freeze
+
+import (
+	"fmt"
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+
+	"github.com/opensourceways/yabot/gitee/gitee"
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+const (
+	pluginName = "freeze"
+
+	// freezeLabel is added to the pull requests blocked by a freeze.
+	freezeLabel = "do-not-merge/freeze"
+)
+
+type freeze struct {
+	getPluginConfig plugins.GetRepoPluginConfig
+	gc              giteeClient
+}
+
+// NewFreeze returns the freeze plugin. It labels the pull requests both when
+// they are opened and periodically, so that the label is added when a freeze
+// starts and removed when it ends.
+func NewFreeze(f plugins.GetRepoPluginConfig, gc giteeClient) plugins.Plugin {
+	return &freeze{
+		getPluginConfig: f,
+		gc:              gc,
+	}
+}
+
+// Checker returns a function which returns the reason if the pull request
+// must not be merged because of a freeze, or empty if it can be merged. It
+// is used by the merge automation to honor the freeze.
+func Checker(f plugins.GetRepoPluginConfig) plugins.MergeChecker {
+	return func(org, repo string, pr *sdk.PullRequest) string {
+		c, ok := f(pluginName, org, repo).(*configuration)
+		if !ok {
+			return ""
+		}
+
+		author := ""
+		if pr.User != nil {
+			author = pr.User.Login
+		}
+		labels := sets.NewString()
+		for _, l := range pr.Labels {
+			labels.Insert(l.Name)
+		}
+
+		if w := activeFreeze(c, org, repo, pr.Base.Ref, author, labels); w != nil {
+			return reason(pr.Base.Ref, w)
+		}
+		return ""
+	}
+}
+
+func (this *freeze) HelpProvider(_ []prowConfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	return &pluginhelp.PluginHelp{
+		Description: fmt.Sprintf("The freeze plugin adds the '%s' label to the open pull requests whose target branches are frozen by the release managers, and removes it when the freeze ends. The pull requests with the exempt label or from the exempt users are not frozen.", freezeLabel),
+	}, nil
+}
+
+func (this *freeze) PluginName() string {
+	return pluginName
+}
+
+func (this *freeze) NewPluginConfig() plugins.PluginConfig {
+	return &configuration{}
+}
+
+func (this *freeze) RegisterEventHandler(p plugins.Plugins) {
+	p.RegisterPullRequestHandler(this.PluginName(), this.handlePullRequestEvent)
+}
+
+func (this *freeze) handlePullRequestEvent(e *sdk.PullRequestEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handlePullRequest")
+	}()
+
+	if e.PullRequest.State != "open" {
+		log.Debug("Pull request state is not open, skipping...")
+		return nil
+	}
+
+	// The freeze of pull request changes when it is opened, its target
+	// branch is changed or the exempt label is changed.
+	switch plugins.ConvertPullRequestAction(e) {
+	case github.PullRequestActionOpened, github.PullRequestActionEdited, github.PullRequestActionLabeled:
+	default:
+		return nil
+	}
+
+	pr := e.PullRequest
+	org := e.Repository.Namespace
+	repo := e.Repository.Path
+
+	cfg, err := this.pluginConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	author := ""
+	if pr.User != nil {
+		author = pr.User.Login
+	}
+	labels := sets.NewString()
+	for _, l := range pr.Labels {
+		labels.Insert(l.Name)
+	}
+
+	return this.apply(org, repo, int(pr.Number), pr.Base.Ref, author, labels, cfg, log)
+}
+
+// Sync labels the open pull requests of repo according to the freezes in effect.
+func (this *freeze) Sync(org, repo string, enabled func(branch string) bool, log *logrus.Entry) error {
+	cfg, err := this.pluginConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	prs, err := this.gc.GetPullRequests(org, repo, gitee.ListPullRequestOpt{State: "open"})
+	if err != nil {
+		return err
+	}
+
+	for i := range prs {
+		pr := &prs[i]
+		if !enabled(pr.Base.Ref) {
+			continue
+		}
+
+		author := ""
+		if pr.User != nil {
+			author = pr.User.Login
+		}
+		labels := sets.NewString()
+		for _, l := range pr.Labels {
+			labels.Insert(l.Name)
+		}
+
+		l := log.WithField("number", pr.Number)
+		if err := this.apply(org, repo, int(pr.Number), pr.Base.Ref, author, labels, cfg, l); err != nil {
+			l.WithError(err).Error("Error applying the freeze to pull request.")
+		}
+	}
+	return nil
+}
+
+// apply adds the freeze label to the pull request if it is frozen, otherwise
+// removes the label.
+func (this *freeze) apply(org, repo string, number int, branch, author string, labels sets.String, cfg *configuration, log *logrus.Entry) error {
+	w := activeFreeze(cfg, org, repo, branch, author, labels)
+	hasLabel := labels.Has(freezeLabel)
+
+	if w == nil {
+		if !hasLabel {
+			return nil
+		}
+
+		log.Info("Removing the freeze label.")
+		return this.gc.RemovePRLabel(org, repo, number, freezeLabel)
+	}
+
+	if hasLabel {
+		return nil
+	}
+
+	log.Info("Adding the freeze label.")
+	if err := this.gc.AddPRLabel(org, repo, number, freezeLabel); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("This pull request can't be merged for now, since %s The label `%s` will be removed when the freeze ends.", reason(branch, w), freezeLabel)
+	return this.gc.CreatePRComment(org, repo, number, msg)
+}
+
+func (this *freeze) pluginConfig(org, repo string) (*configuration, error) {
+	c := this.getPluginConfig(this.PluginName(), org, repo)
+	if c == nil {
+		return nil, fmt.Errorf("can't find the configuration")
+	}
+
+	c1, ok := c.(*configuration)
+	if !ok {
+		return nil, fmt.Errorf("can't convert to configuration")
+	}
+
+	return c1, nil
+}
+
+// activeFreeze returns the freeze which blocks the pull request now, or nil
+// if it is not frozen or exempted by all the freezes.
+func activeFreeze(c *configuration, org, repo, branch, author string, labels sets.String) *pluginConfig {
+	for _, w := range c.activeFreezes(org, repo, branch, time.Now()) {
+		if !w.exempts(author, labels) {
+			return w
+		}
+	}
+	return nil
+}
+
+func reason(branch string, w *pluginConfig) string {
+	return fmt.Sprintf("the branch `%s` is frozen from %s to %s.", branch, w.Start, w.End)
+}
@@ -0,0 +1,201 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultCircuitBreakerThreshold = 5
+	defaultCircuitBreakerCooldown  = time.Minute
+)
+
+// CircuitState is the state of the circuit breaker of an external plugin endpoint.
+type CircuitState string
+
+const (
+	// CircuitClosed means the events are delivered to the endpoint normally.
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen means the endpoint is unhealthy and the events are not delivered.
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen means one event is delivered to probe whether the endpoint recovers.
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+var (
+	externalPluginCircuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitee_external_plugin_circuit_state",
+		Help: "The circuit state of external plugin endpoint: 0 for closed, 1 for half-open, 2 for open.",
+	}, []string{"plugin", "endpoint"})
+	externalPluginConsecutiveFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitee_external_plugin_consecutive_failures",
+		Help: "The number of consecutive failed deliveries to external plugin endpoint.",
+	}, []string{"plugin", "endpoint"})
+	externalPluginDeliveryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gitee_external_plugin_delivery_duration_seconds",
+		Help:    "How long the hook took to deliver an event to external plugin endpoint, by result.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 80},
+	}, []string{"plugin", "endpoint", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(externalPluginCircuitState)
+	prometheus.MustRegister(externalPluginConsecutiveFailures)
+	prometheus.MustRegister(externalPluginDeliveryDuration)
+}
+
+// EndpointHealth is the health of an external plugin endpoint.
+type EndpointHealth struct {
+	Plugin              string        `json:"plugin"`
+	Endpoint            string        `json:"endpoint"`
+	State               CircuitState  `json:"state"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	LastSuccess         time.Time     `json:"last_success,omitempty"`
+	LastFailure         time.Time     `json:"last_failure,omitempty"`
+	LastError           string        `json:"last_error,omitempty"`
+	LastLatency         time.Duration `json:"last_latency"`
+	OpenedAt            time.Time     `json:"opened_at,omitempty"`
+
+	// probing is true when an event is being delivered in half-open state.
+	probing bool
+}
+
+// ExternalPluginHealth tracks the health of external plugin endpoints and
+// works as their circuit breakers. It serves the health as JSON.
+type ExternalPluginHealth struct {
+	mut       sync.Mutex
+	endpoints map[string]*EndpointHealth
+}
+
+func NewExternalPluginHealth() *ExternalPluginHealth {
+	return &ExternalPluginHealth{endpoints: map[string]*EndpointHealth{}}
+}
+
+// allow reports whether an event can be delivered to the endpoint of plugin now.
+func (h *ExternalPluginHealth) allow(p ExternalPlugin) bool {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	e := h.get(p)
+	switch e.State {
+	case CircuitOpen:
+		if time.Since(e.OpenedAt) < p.circuitBreakerCooldown() {
+			return false
+		}
+		e.State = CircuitHalfOpen
+		e.probing = true
+		setCircuitStateMetric(e)
+		return true
+
+	case CircuitHalfOpen:
+		// Only one probe is allowed at the same time.
+		if e.probing {
+			return false
+		}
+		e.probing = true
+		return true
+	}
+	return true
+}
+
+// record updates the health of the endpoint of plugin by the result of a delivery.
+func (h *ExternalPluginHealth) record(p ExternalPlugin, latency time.Duration, err error) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	e := h.get(p)
+	e.probing = false
+	e.LastLatency = latency
+
+	result := "success"
+	if err == nil {
+		e.State = CircuitClosed
+		e.ConsecutiveFailures = 0
+		e.LastSuccess = time.Now()
+	} else {
+		result = "failure"
+		e.ConsecutiveFailures++
+		e.LastFailure = time.Now()
+		e.LastError = err.Error()
+
+		if e.State == CircuitHalfOpen || e.ConsecutiveFailures >= p.circuitBreakerThreshold() {
+			e.State = CircuitOpen
+			e.OpenedAt = time.Now()
+		}
+	}
+
+	setCircuitStateMetric(e)
+	externalPluginConsecutiveFailures.WithLabelValues(e.Plugin, e.Endpoint).Set(float64(e.ConsecutiveFailures))
+	externalPluginDeliveryDuration.WithLabelValues(e.Plugin, e.Endpoint, result).Observe(latency.Seconds())
+}
+
+// Status returns the health of all the endpoints which have been delivered to.
+func (h *ExternalPluginHealth) Status() []EndpointHealth {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	r := make([]EndpointHealth, 0, len(h.endpoints))
+	for _, e := range h.endpoints {
+		r = append(r, *e)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Plugin != r[j].Plugin {
+			return r[i].Plugin < r[j].Plugin
+		}
+		return r[i].Endpoint < r[j].Endpoint
+	})
+	return r
+}
+
+// ServeHTTP serves the health of all the endpoints as JSON.
+func (h *ExternalPluginHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := json.Marshal(h.Status())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("500 Internal server error marshaling status: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func (h *ExternalPluginHealth) get(p ExternalPlugin) *EndpointHealth {
+	k := p.Name + "@" + p.Endpoint
+	e, ok := h.endpoints[k]
+	if !ok {
+		e = &EndpointHealth{Plugin: p.Name, Endpoint: p.Endpoint, State: CircuitClosed}
+		h.endpoints[k] = e
+	}
+	return e
+}
+
+func setCircuitStateMetric(e *EndpointHealth) {
+	v := 0
+	switch e.State {
+	case CircuitHalfOpen:
+		v = 1
+	case CircuitOpen:
+		v = 2
+	}
+	externalPluginCircuitState.WithLabelValues(e.Plugin, e.Endpoint).Set(float64(v))
+}
+
+func (p ExternalPlugin) circuitBreakerThreshold() int {
+	if p.CircuitBreakerThreshold > 0 {
+		return p.CircuitBreakerThreshold
+	}
+	return defaultCircuitBreakerThreshold
+}
+
+func (p ExternalPlugin) circuitBreakerCooldown() time.Duration {
+	if p.CircuitBreakerCooldown != nil && p.CircuitBreakerCooldown.Duration > 0 {
+		return p.CircuitBreakerCooldown.Duration
+	}
+	return defaultCircuitBreakerCooldown
+}
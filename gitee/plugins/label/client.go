@@ -0,0 +1,15 @@
+package label
+
+import (
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+)
+
+type giteeClient interface {
+	GetRepoLabels(owner, repo string) ([]sdk.Label, error)
+	AddPRLabel(owner, repo string, number int, label string) error
+	RemovePRLabel(owner, repo string, number int, label string) error
+	CreatePRComment(owner, repo string, number int, comment string) error
+	AddIssueLabel(org, repo, number, label string) error
+	RemoveIssueLabel(org, repo, number, label string) error
+	CreateGiteeIssueComment(org, repo string, number string, comment string) error
+}
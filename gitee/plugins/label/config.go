@@ -0,0 +1,42 @@
+package label
+
+import (
+	"github.com/huaweicloud/golangsdk"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+type configuration struct {
+	Label []pluginConfig `json:"label,omitempty"`
+}
+
+func (c *configuration) Validate() error {
+	_, err := golangsdk.BuildRequestBody(c, "")
+	return err
+}
+
+func (c *configuration) SetDefault() {
+}
+
+// LabelFor returns the config for the repo. The config of repo takes
+// precedence over the one of its org. It is nil if neither is set.
+func (c *configuration) LabelFor(org, repo string) *pluginConfig {
+	i := plugins.FindConfig(org, repo, len(c.Label), func(i int) []string {
+		return c.Label[i].Repos
+	})
+	if i < 0 {
+		return nil
+	}
+	return &(c.Label[i])
+}
+
+type pluginConfig struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos" required:"true"`
+
+	// AdditionalLabels are the only labels which can be applied or removed
+	// by /label and /remove-label. They can be added by the commands even
+	// if they don't exist in the repo, since Gitee creates them when they
+	// are added.
+	AdditionalLabels []string `json:"additional_labels,omitempty"`
+}
@@ -0,0 +1,292 @@
+package label
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/pluginhelp"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+	originp "github.com/opensourceways/yabot/prow/plugins"
+)
+
+const pluginName = "label"
+
+var (
+	labelRe               = regexp.MustCompile(`(?m)^/(area|kind|priority|sig)\s+(.+?)\s*$`)
+	removeLabelRe         = regexp.MustCompile(`(?m)^/remove-(area|kind|priority|sig)\s+(.+?)\s*$`)
+	customLabelRe         = regexp.MustCompile(`(?m)^/label\s+(.+?)\s*$`)
+	customRemoveLabelRe   = regexp.MustCompile(`(?m)^/remove-label\s+(.+?)\s*$`)
+	nonExistentLabelResp  = "The label(s) `%s` cannot be applied, because the repository doesn't have them."
+	nonExistentOnResp     = "The label(s) `%s` cannot be removed, because the %s doesn't have them."
+	noAdditionalLabelResp = "The label(s) `%s` cannot be applied or removed, because no label can be applied or removed by `/label` and `/remove-label` in the repository."
+	unsupportedLabelResp  = "The label(s) `%s` cannot be applied or removed by `/label` and `/remove-label`. These labels are supported: `%s`."
+)
+
+type label struct {
+	getPluginConfig plugins.GetRepoPluginConfig
+	gc              giteeClient
+}
+
+// NewLabel returns the label plugin which handles the label commands on both
+// pull requests and issues.
+func NewLabel(f plugins.GetRepoPluginConfig, gc giteeClient) plugins.Plugin {
+	return &label{
+		getPluginConfig: f,
+		gc:              gc,
+	}
+}
+
+func (this *label) HelpProvider(_ []prowConfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	pluginHelp := &pluginhelp.PluginHelp{
+		Description: "The label plugin provides commands that add or remove certain types of labels on pull requests and issues. The labels of the recognized types must exist in the repository or be configured as the additional labels, and the other labels must be configured as the additional labels.",
+	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/[remove-](area|kind|priority|sig) <target>",
+		Description: "Applies or removes a label from one of the recognized types of labels.",
+		Featured:    false,
+		WhoCanUse:   "Anyone",
+		Examples:    []string{"/kind bug", "/remove-area prow", "/sig testing"},
+	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/[remove-]label <target>",
+		Description: "Applies or removes a label from the additional labels configured for the repository.",
+		Featured:    false,
+		WhoCanUse:   "Anyone",
+		Examples:    []string{"/label good-first-issue", "/remove-label good-first-issue"},
+	})
+	return pluginHelp, nil
+}
+
+func (this *label) PluginName() string {
+	return pluginName
+}
+
+func (this *label) NewPluginConfig() plugins.PluginConfig {
+	return &configuration{}
+}
+
+func (this *label) RegisterEventHandler(p plugins.Plugins) {
+	p.RegisterNoteEventHandler(this.PluginName(), this.handleNoteEvent)
+}
+
+// target is the pull request or issue which the labels are applied to.
+type target struct {
+	kind   string
+	labels []sdk.LabelHook
+
+	addLabel    func(string) error
+	removeLabel func(string) error
+	comment     func(string) error
+}
+
+func (this *label) handleNoteEvent(e *sdk.NoteEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handleNoteEvent")
+	}()
+
+	if *(e.Action) != "comment" {
+		log.Debug("Event is not a creation of a comment, skipping.")
+		return nil
+	}
+
+	body := e.Comment.Body
+	toAdd := parseLabels(labelRe, body)
+	toRemove := parseLabels(removeLabelRe, body)
+	customToAdd := parseCustomLabels(customLabelRe, body)
+	customToRemove := parseCustomLabels(customRemoveLabelRe, body)
+	if len(toAdd) == 0 && len(toRemove) == 0 && len(customToAdd) == 0 && len(customToRemove) == 0 {
+		return nil
+	}
+
+	org := e.Repository.Namespace
+	repo := e.Repository.Path
+
+	var t target
+	switch *(e.NoteableType) {
+	case "PullRequest":
+		number := int(e.PullRequest.Number)
+		t = target{
+			kind:   "pull request",
+			labels: e.PullRequest.Labels,
+			addLabel: func(l string) error {
+				return this.gc.AddPRLabel(org, repo, number, l)
+			},
+			removeLabel: func(l string) error {
+				return this.gc.RemovePRLabel(org, repo, number, l)
+			},
+			comment: func(c string) error {
+				return this.gc.CreatePRComment(org, repo, number, c)
+			},
+		}
+
+	case "Issue":
+		number := e.Issue.Number
+		t = target{
+			kind:   "issue",
+			labels: e.Issue.Labels,
+			addLabel: func(l string) error {
+				return this.gc.AddIssueLabel(org, repo, number, l)
+			},
+			removeLabel: func(l string) error {
+				return this.gc.RemoveIssueLabel(org, repo, number, l)
+			},
+			comment: func(c string) error {
+				return this.gc.CreateGiteeIssueComment(org, repo, number, c)
+			},
+		}
+
+	default:
+		return nil
+	}
+
+	cfg, err := this.pluginConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	repoLabels, err := this.gc.GetRepoLabels(org, repo)
+	if err != nil {
+		return err
+	}
+
+	// The labels are matched case insensitively, and applied with the
+	// names in the repo. Only the additional labels can be applied or
+	// removed by /label and /remove-label, which keeps the labels such as
+	// lgtm and approved from being changed by anyone.
+	var additionalLabels []string
+	if pc := cfg.LabelFor(org, repo); pc != nil {
+		additionalLabels = pc.AdditionalLabels
+	}
+	additional := map[string]string{}
+	for _, l := range additionalLabels {
+		additional[strings.ToLower(l)] = l
+	}
+
+	existing := map[string]string{}
+	for _, l := range repoLabels {
+		existing[strings.ToLower(l.Name)] = l.Name
+	}
+	for k, v := range additional {
+		existing[k] = v
+	}
+
+	var unsupported []string
+	for _, l := range customToAdd {
+		if _, ok := additional[strings.ToLower(l)]; ok {
+			toAdd = append(toAdd, l)
+		} else {
+			unsupported = append(unsupported, l)
+		}
+	}
+	for _, l := range customToRemove {
+		if _, ok := additional[strings.ToLower(l)]; ok {
+			toRemove = append(toRemove, l)
+		} else {
+			unsupported = append(unsupported, l)
+		}
+	}
+
+	current := map[string]string{}
+	for _, l := range t.labels {
+		current[strings.ToLower(l.Name)] = l.Name
+	}
+
+	var errs []error
+	var nonexistent, notOn []string
+
+	for _, l := range toAdd {
+		name, ok := existing[strings.ToLower(l)]
+		if !ok {
+			nonexistent = append(nonexistent, l)
+			continue
+		}
+
+		if _, ok := current[strings.ToLower(l)]; ok {
+			continue
+		}
+
+		if err := t.addLabel(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, l := range toRemove {
+		name, ok := current[strings.ToLower(l)]
+		if !ok {
+			notOn = append(notOn, l)
+			continue
+		}
+
+		if err := t.removeLabel(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var resp []string
+	if len(unsupported) > 0 {
+		if len(additionalLabels) == 0 {
+			resp = append(resp, fmt.Sprintf(noAdditionalLabelResp, strings.Join(unsupported, ", ")))
+		} else {
+			resp = append(resp, fmt.Sprintf(unsupportedLabelResp, strings.Join(unsupported, ", "), strings.Join(additionalLabels, ", ")))
+		}
+	}
+	if len(nonexistent) > 0 {
+		resp = append(resp, fmt.Sprintf(nonExistentLabelResp, strings.Join(nonexistent, ", ")))
+	}
+	if len(notOn) > 0 {
+		resp = append(resp, fmt.Sprintf(nonExistentOnResp, strings.Join(notOn, ", "), t.kind))
+	}
+	if len(resp) > 0 {
+		log.Infof("Responding to the invalid labels: %v, %v, %v.", unsupported, nonexistent, notOn)
+
+		msg := originp.FormatResponseRaw(body, e.Comment.HtmlUrl, e.Comment.User.Login, strings.Join(resp, "\n\n"))
+		if err := t.comment(msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func (this *label) pluginConfig(org, repo string) (*configuration, error) {
+	c := this.getPluginConfig(this.PluginName(), org, repo)
+	if c == nil {
+		return nil, fmt.Errorf("can't find the configuration")
+	}
+
+	c1, ok := c.(*configuration)
+	if !ok {
+		return nil, fmt.Errorf("can't convert to configuration")
+	}
+
+	return c1, nil
+}
+
+// parseLabels returns the labels of the form type/name in the commands, such
+// as "kind/bug" of "/kind bug".
+func parseLabels(re *regexp.Regexp, body string) []string {
+	var r []string
+	for _, m := range re.FindAllStringSubmatch(body, -1) {
+		for _, name := range strings.Fields(m[2]) {
+			r = append(r, strings.ToLower(m[1]+"/"+name))
+		}
+	}
+	return r
+}
+
+// parseCustomLabels returns the labels of commands as they are.
+func parseCustomLabels(re *regexp.Regexp, body string) []string {
+	var r []string
+	for _, m := range re.FindAllStringSubmatch(body, -1) {
+		r = append(r, strings.Fields(m[1])...)
+	}
+	return r
+}
@@ -0,0 +1,165 @@
+package lgtm
+
+import (
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/test-infra/prow/github"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+type giteeClient interface {
+	BotName() (string, error)
+	IsCollaborator(owner, repo, login string) (bool, error)
+	ListCollaborators(org, repo string) ([]github.User, error)
+	IsMember(org, login string) (bool, error)
+	AddPRLabel(owner, repo string, number int, label string) error
+	RemovePRLabel(owner, repo string, number int, label string) error
+	GetPRLabels(org, repo string, number int) ([]sdk.Label, error)
+	AssignPR(owner, repo string, number int, logins []string) error
+	CreatePRComment(owner, repo string, number int, comment string) error
+	ListPRComments(org, repo string, number int) ([]sdk.PullRequestComments, error)
+	DeletePRComment(org, repo string, ID int) error
+	GetGiteePullRequest(org, repo string, number int) (sdk.PullRequest, error)
+	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+	GetSingleCommit(org, repo, SHA string) (github.SingleCommit, error)
+}
+
+// ghclient adapts giteeClient to the client of original lgtm plugin.
+type ghclient struct {
+	giteeClient
+}
+
+// IsCollaborator falls back to list the collaborators if gitee fails to
+// check the collaborator directly.
+func (c *ghclient) IsCollaborator(owner, repo, login string) (bool, error) {
+	return plugins.IsCollaborator(c.giteeClient, owner, repo, login)
+}
+
+func (c *ghclient) AddLabel(owner, repo string, number int, label string) error {
+	return c.AddPRLabel(owner, repo, number, label)
+}
+
+func (c *ghclient) RemoveLabel(owner, repo string, number int, label string) error {
+	return c.RemovePRLabel(owner, repo, number, label)
+}
+
+func (c *ghclient) AssignIssue(owner, repo string, number int, assignees []string) error {
+	return c.AssignPR(owner, repo, number, assignees)
+}
+
+func (c *ghclient) CreateComment(owner, repo string, number int, comment string) error {
+	return c.CreatePRComment(owner, repo, number, comment)
+}
+
+func (c *ghclient) DeleteComment(org, repo string, ID int) error {
+	return c.DeletePRComment(org, repo, ID)
+}
+
+func (c *ghclient) GetIssueLabels(org, repo string, number int) ([]github.Label, error) {
+	ls, err := c.GetPRLabels(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]github.Label, 0, len(ls))
+	for _, item := range ls {
+		r = append(r, github.Label{Name: item.Name})
+	}
+	return r, nil
+}
+
+func (c *ghclient) GetPullRequest(org, repo string, number int) (*github.PullRequest, error) {
+	pr, err := c.GetGiteePullRequest(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &github.PullRequest{
+		Number: int(pr.Number),
+		State:  pr.State,
+	}
+	if pr.Base != nil {
+		r.Base.Ref = pr.Base.Ref
+	}
+	if pr.Head != nil {
+		r.Head.Ref = pr.Head.Ref
+		r.Head.SHA = pr.Head.Sha
+	}
+	if pr.User != nil {
+		r.User.Login = pr.User.Login
+	}
+	return r, nil
+}
+
+func (c *ghclient) ListIssueComments(org, repo string, number int) ([]github.IssueComment, error) {
+	cs, err := c.ListPRComments(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]github.IssueComment, 0, len(cs))
+	for _, item := range cs {
+		v := github.IssueComment{
+			ID:        int(item.Id),
+			Body:      item.Body,
+			HTMLURL:   item.HtmlUrl,
+			CreatedAt: parseTime(item.CreatedAt),
+			UpdatedAt: parseTime(item.UpdatedAt),
+		}
+		if item.User != nil {
+			v.User.Login = item.User.Login
+		}
+		r = append(r, v)
+	}
+	return r, nil
+}
+
+// ListTeams returns nothing, since gitee doesn't support the teams. So the
+// sticky lgtm never applies.
+func (c *ghclient) ListTeams(org string) ([]github.Team, error) {
+	return nil, nil
+}
+
+func (c *ghclient) ListTeamMembers(id int, role string) ([]github.TeamMember, error) {
+	return nil, nil
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+// commentPruner deletes the comments of bot on the pull request.
+type commentPruner struct {
+	ghc    *ghclient
+	org    string
+	repo   string
+	number int
+	log    *logrus.Entry
+}
+
+func (cp *commentPruner) PruneComments(shouldPrune func(github.IssueComment) bool) {
+	botName, err := cp.ghc.BotName()
+	if err != nil {
+		cp.log.WithError(err).Error("Failed to get the bot's name.")
+		return
+	}
+
+	comments, err := cp.ghc.ListIssueComments(cp.org, cp.repo, cp.number)
+	if err != nil {
+		cp.log.WithError(err).Error("Failed to list comments.")
+		return
+	}
+
+	for _, c := range comments {
+		if c.User.Login != botName || !shouldPrune(c) {
+			continue
+		}
+		if err := cp.ghc.DeleteComment(cp.org, cp.repo, c.ID); err != nil {
+			cp.log.WithError(err).WithField("id", c.ID).Error("Failed to delete comment.")
+		}
+	}
+}
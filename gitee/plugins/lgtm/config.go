@@ -0,0 +1,85 @@
+package lgtm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/huaweicloud/golangsdk"
+	originp "k8s.io/test-infra/prow/plugins"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+type configuration struct {
+	Lgtm []pluginConfig `json:"lgtm,omitempty"`
+}
+
+func (c *configuration) Validate() error {
+	_, err := golangsdk.BuildRequestBody(c, "")
+	return err
+}
+
+func (c *configuration) SetDefault() {
+}
+
+// LgtmFor returns the config for the repo. The config of repo takes
+// precedence over the one of its org. It is nil if neither is set.
+func (c *configuration) LgtmFor(org, repo string) *pluginConfig {
+	i := plugins.FindConfig(org, repo, len(c.Lgtm), func(i int) []string {
+		return c.Lgtm[i].Repos
+	})
+	if i < 0 {
+		return nil
+	}
+	return &(c.Lgtm[i])
+}
+
+// OverrideFor returns the config for the repo, in which store_tree_hash is
+// overridden by the config in repo. The other settings can't be overridden.
+func (c *configuration) OverrideFor(org, repo string, b []byte) (plugins.PluginConfig, error) {
+	var o struct {
+		StoreTreeHash *bool `json:"store_tree_hash,omitempty"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		return nil, err
+	}
+
+	var v pluginConfig
+	if pc := c.LgtmFor(org, repo); pc != nil {
+		v = *pc
+	}
+	v.Repos = []string{fmt.Sprintf("%s/%s", org, repo)}
+	if o.StoreTreeHash != nil {
+		v.StoreTreeHash = *o.StoreTreeHash
+	}
+	return &configuration{Lgtm: []pluginConfig{v}}, nil
+}
+
+type pluginConfig struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos" required:"true"`
+
+	// StoreTreeHash indicates if tree_hash should be stored inside a comment to detect
+	// squashed commits before removing lgtm labels
+	StoreTreeHash bool `json:"store_tree_hash,omitempty"`
+}
+
+// originConfig converts the config of repo to the one of original lgtm plugin.
+func originConfig(org, repo string, cfg *pluginConfig, skipCollaborators bool) *originp.Configuration {
+	fullName := fmt.Sprintf("%s/%s", org, repo)
+
+	c := &originp.Configuration{}
+	if cfg != nil {
+		c.Lgtm = []originp.Lgtm{{
+			Repos:         []string{fullName},
+			StoreTreeHash: cfg.StoreTreeHash,
+		}}
+	}
+	if skipCollaborators {
+		c.Owners.SkipCollaborators = []string{fullName}
+	}
+	return c
+}
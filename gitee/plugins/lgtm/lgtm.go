@@ -0,0 +1,161 @@
+package lgtm
+
+import (
+	"fmt"
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+	originp "k8s.io/test-infra/prow/plugins"
+	"k8s.io/test-infra/prow/repoowners"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+	originl "github.com/opensourceways/yabot/prow/plugins/lgtm"
+)
+
+type lgtm struct {
+	getPluginConfig   plugins.GetRepoPluginConfig
+	ghc               *ghclient
+	oc                repoowners.Interface
+	skipCollaborators func(org, repo string) bool
+}
+
+// NewLGTM returns the lgtm plugin. skipCollaborators reports whether the
+// reviewers of repo are checked by OWNERS files instead of collaborators.
+func NewLGTM(f plugins.GetRepoPluginConfig, gc giteeClient, oc repoowners.Interface, skipCollaborators func(org, repo string) bool) plugins.Plugin {
+	return &lgtm{
+		getPluginConfig:   f,
+		ghc:               &ghclient{giteeClient: gc},
+		oc:                oc,
+		skipCollaborators: skipCollaborators,
+	}
+}
+
+func (this *lgtm) HelpProvider(_ []prowConfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	pluginHelp := &pluginhelp.PluginHelp{
+		Description: "The lgtm plugin manages the application and removal of the 'lgtm' (Looks Good To Me) label which is typically used to gate merging. The label is removed when the source branch of pull request changes, unless the tree hash is stored and kept the same.",
+	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/lgtm [cancel]",
+		Description: "Adds or removes the 'lgtm' label which is typically used to gate merging.",
+		Featured:    true,
+		WhoCanUse:   "Collaborators on the repository, or the reviewers and approvers in OWNERS files if collaborators are skipped. '/lgtm cancel' can be used additionally by the PR author.",
+		Examples:    []string{"/lgtm", "/lgtm cancel"},
+	})
+	return pluginHelp, nil
+}
+
+func (this *lgtm) PluginName() string {
+	return originl.PluginName
+}
+
+func (this *lgtm) NewPluginConfig() plugins.PluginConfig {
+	return &configuration{}
+}
+
+func (this *lgtm) RegisterEventHandler(p plugins.Plugins) {
+	name := this.PluginName()
+	p.RegisterNoteEventHandler(name, this.handleNoteEvent)
+	p.RegisterPullRequestHandler(name, this.handlePullRequestEvent)
+}
+
+func (this *lgtm) handleNoteEvent(e *sdk.NoteEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handleNoteEvent")
+	}()
+
+	if *(e.NoteableType) != "PullRequest" {
+		return nil
+	}
+
+	ge := plugins.NoteEventToCommentEvent(e)
+
+	// Only consider open PRs and new comments.
+	if ge.IssueState != "open" || ge.Action != github.GenericCommentActionCreated {
+		return nil
+	}
+
+	// If we create an "/lgtm" comment, add lgtm if necessary.
+	// If we create a "/lgtm cancel" comment, remove lgtm if necessary.
+	wantLGTM := false
+	if originl.LGTMRe.MatchString(ge.Body) {
+		wantLGTM = true
+	} else if !originl.LGTMCancelRe.MatchString(ge.Body) {
+		return nil
+	}
+
+	// The author of pull request is the user who creates it, not the one of
+	// the source branch.
+	if pr := e.PullRequest; pr.User != nil {
+		ge.IssueAuthor.Login = pr.User.Login
+	}
+
+	org, repo := ge.Repo.Owner.Login, ge.Repo.Name
+	cfg, err := this.originConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	rc := originl.NewReviewCtx(ge.User.Login, ge.IssueAuthor.Login, ge.Body, ge.HTMLURL, ge.Repo, ge.Assignees, ge.Number)
+	cp := &commentPruner{ghc: this.ghc, org: org, repo: repo, number: ge.Number, log: log}
+	return originl.Handle(wantLGTM, cfg, this.oc, rc, this.ghc, log, cp)
+}
+
+func (this *lgtm) handlePullRequestEvent(e *sdk.PullRequestEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handlePullRequest")
+	}()
+
+	if e.PullRequest.State != "open" {
+		log.Debug("Pull request state is not open, skipping...")
+		return nil
+	}
+
+	// Only the changes of source branch remove the label.
+	if plugins.ConvertPullRequestAction(e) != github.PullRequestActionSynchronize {
+		return nil
+	}
+
+	pe := plugins.ConvertPullRequestEvent(e)
+	if u := e.PullRequest.User; u != nil {
+		pe.PullRequest.User.Login = u.Login
+	}
+
+	repo := pe.PullRequest.Base.Repo
+	cfg, err := this.originConfig(repo.Owner.Login, repo.Name)
+	if err != nil {
+		return err
+	}
+
+	return originl.HandlePullRequest(log, this.ghc, cfg, &pe)
+}
+
+// originConfig returns the config of original lgtm plugin for the repo.
+func (this *lgtm) originConfig(org, repo string) (*originp.Configuration, error) {
+	cfg, err := this.pluginConfig(org, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := this.skipCollaborators != nil && this.skipCollaborators(org, repo)
+	return originConfig(org, repo, cfg.LgtmFor(org, repo), skip), nil
+}
+
+func (this *lgtm) pluginConfig(org, repo string) (*configuration, error) {
+	c := this.getPluginConfig(this.PluginName(), org, repo)
+	if c == nil {
+		return nil, fmt.Errorf("can't find the configuration")
+	}
+
+	c1, ok := c.(*configuration)
+	if !ok {
+		return nil, fmt.Errorf("can't convert to configuration")
+	}
+
+	return c1, nil
+}
@@ -0,0 +1,16 @@
+package merge
+
+import (
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+
+	"github.com/opensourceways/yabot/gitee/gitee"
+)
+
+type giteeClient interface {
+	BotName() (string, error)
+	GetGiteePullRequest(org, repo string, number int) (sdk.PullRequest, error)
+	GetPullRequests(org, repo string, opts gitee.ListPullRequestOpt) ([]sdk.PullRequest, error)
+	ListPRComments(org, repo string, number int) ([]sdk.PullRequestComments, error)
+	CreatePRComment(owner, repo string, number int, comment string) error
+	MergePR(owner, repo string, number int, opt sdk.PullRequestMergePutParam) error
+}
@@ -0,0 +1,116 @@
+package merge
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/huaweicloud/golangsdk"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+var mergeMethods = sets.NewString("merge", "squash", "rebase")
+
+type configuration struct {
+	Merge []pluginConfig `json:"merge,omitempty"`
+}
+
+func (c *configuration) Validate() error {
+	if _, err := golangsdk.BuildRequestBody(c, ""); err != nil {
+		return err
+	}
+
+	for i := range c.Merge {
+		if err := c.Merge[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *configuration) SetDefault() {
+	for i := range c.Merge {
+		item := &(c.Merge[i])
+
+		if item.BlockingLabels == nil {
+			item.BlockingLabels = []string{"do-not-merge/*"}
+		}
+		if item.MergeMethod == "" {
+			item.MergeMethod = "merge"
+		}
+	}
+}
+
+// MergeFor returns the config for the repo. The config of repo takes
+// precedence over the one of its org.
+func (c *configuration) MergeFor(org, repo string) *pluginConfig {
+	i := plugins.FindConfig(org, repo, len(c.Merge), func(i int) []string {
+		return c.Merge[i].Repos
+	})
+	if i < 0 {
+		return nil
+	}
+	return &(c.Merge[i])
+}
+
+// CheckEnabledRepos checks that each org and repo enabling merge has its config.
+func (c *configuration) CheckEnabledRepos(orgs, repos []string) error {
+	var errs []error
+	for _, org := range orgs {
+		if c.MergeFor(org, "") == nil {
+			errs = append(errs, fmt.Errorf("merge is enabled for %s, but no merge config for it", org))
+		}
+	}
+
+	for _, repo := range repos {
+		v := strings.SplitN(repo, "/", 2)
+		if c.MergeFor(v[0], v[1]) == nil {
+			errs = append(errs, fmt.Errorf("merge is enabled for %s, but no merge config for it", repo))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+type pluginConfig struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos" required:"true"`
+
+	// RequiredLabels are the labels which a pull request must have to be
+	// merged, such as "lgtm" and "approved".
+	RequiredLabels []string `json:"required_labels" required:"true"`
+
+	// BlockingLabels are the globs of labels which prevent a pull request
+	// from being merged. Defaults to "do-not-merge/*".
+	BlockingLabels []string `json:"blocking_labels,omitempty"`
+
+	// MergeMethod is one of "merge", "squash" and "rebase". Defaults to "merge".
+	MergeMethod string `json:"merge_method,omitempty"`
+}
+
+func (p *pluginConfig) validate() error {
+	if !mergeMethods.Has(p.MergeMethod) {
+		return fmt.Errorf("unknown merge_method: %s", p.MergeMethod)
+	}
+
+	for _, glob := range p.BlockingLabels {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid blocking label %q: %v", glob, err)
+		}
+	}
+	return nil
+}
+
+// blockingLabel returns the first label which matches the blocking labels.
+func (p *pluginConfig) blockingLabel(labels sets.String) string {
+	for _, l := range labels.List() {
+		for _, glob := range p.BlockingLabels {
+			if ok, _ := path.Match(glob, l); ok {
+				return l
+			}
+		}
+	}
+	return ""
+}
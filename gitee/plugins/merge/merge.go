@@ -0,0 +1,235 @@
+package merge
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/pluginhelp"
+
+	"github.com/opensourceways/yabot/gitee/gitee"
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+const (
+	pluginName = "merge"
+
+	skipNotification = "This pull request can not be merged automatically: %s"
+)
+
+var skipNotificationRe = regexp.MustCompile(fmt.Sprintf(skipNotification, ".*"))
+
+type merge struct {
+	getPluginConfig plugins.GetRepoPluginConfig
+	gc              giteeClient
+	frozen          plugins.MergeChecker
+}
+
+// NewMerge returns the merge plugin. It merges the pull requests both when
+// their labels change and periodically. frozen returns the reason if the pull
+// request must not be merged because of a freeze, and can be nil.
+func NewMerge(f plugins.GetRepoPluginConfig, gc giteeClient, frozen plugins.MergeChecker) plugins.Plugin {
+	return &merge{
+		getPluginConfig: f,
+		gc:              gc,
+		frozen:          frozen,
+	}
+}
+
+func (this *merge) HelpProvider(_ []prowConfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	return &pluginhelp.PluginHelp{
+		Description: "The merge plugin merges the open pull requests which have all the required labels and none of the blocking labels, such as 'do-not-merge/*', unless their target branches are frozen. It comments the reason if such a pull request can't be merged.",
+	}, nil
+}
+
+func (this *merge) PluginName() string {
+	return pluginName
+}
+
+func (this *merge) NewPluginConfig() plugins.PluginConfig {
+	return &configuration{}
+}
+
+func (this *merge) RegisterEventHandler(p plugins.Plugins) {
+	p.RegisterPullRequestHandler(this.PluginName(), this.handlePullRequestEvent)
+}
+
+func (this *merge) handlePullRequestEvent(e *sdk.PullRequestEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handlePullRequest")
+	}()
+
+	if e.PullRequest.State != "open" {
+		log.Debug("Pull request state is not open, skipping...")
+		return nil
+	}
+
+	if plugins.ConvertPullRequestAction(e) != github.PullRequestActionLabeled {
+		return nil
+	}
+
+	org := e.Repository.Namespace
+	repo := e.Repository.Path
+
+	cfg, err := this.orgRepoConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	// The labels of event may be out of date when the label is changed
+	// by plugins concurrently.
+	pr, err := this.gc.GetGiteePullRequest(org, repo, int(e.PullRequest.Number))
+	if err != nil {
+		return err
+	}
+
+	return this.tryMerge(org, repo, &pr, cfg, log)
+}
+
+// Sync tries to merge the open pull requests of repo which have all the
+// required labels.
+func (this *merge) Sync(org, repo string, enabled func(branch string) bool, log *logrus.Entry) error {
+	cfg, err := this.orgRepoConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	prs, err := this.gc.GetPullRequests(org, repo, gitee.ListPullRequestOpt{
+		State:  "open",
+		Labels: cfg.RequiredLabels,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range prs {
+		pr := &prs[i]
+		if !enabled(pr.Base.Ref) {
+			continue
+		}
+
+		l := log.WithField("number", pr.Number)
+		if err := this.tryMerge(org, repo, pr, cfg, l); err != nil {
+			l.WithError(err).Error("Error merging pull request.")
+		}
+	}
+	return nil
+}
+
+// tryMerge merges the pull request if it is ready. It comments the reason if
+// the pull request has all the required labels but can't be merged.
+func (this *merge) tryMerge(org, repo string, pr *sdk.PullRequest, cfg *pluginConfig, log *logrus.Entry) error {
+	number := int(pr.Number)
+
+	labels := sets.NewString()
+	for _, l := range pr.Labels {
+		labels.Insert(l.Name)
+	}
+
+	if missing := sets.NewString(cfg.RequiredLabels...).Difference(labels); missing.Len() > 0 {
+		log.Debugf("Missing the required labels: %s.", strings.Join(missing.List(), ", "))
+		return nil
+	}
+
+	if l := cfg.blockingLabel(labels); l != "" {
+		return this.skip(org, repo, number, fmt.Sprintf("it has the label `%s`.", l), log)
+	}
+
+	// The freeze is checked even if its label is removed by someone.
+	if this.frozen != nil {
+		if reason := this.frozen(org, repo, pr); reason != "" {
+			return this.skip(org, repo, number, reason, log)
+		}
+	}
+
+	if !pr.Mergeable {
+		return this.skip(org, repo, number, "it has conflicts with the target branch, please rebase it.", log)
+	}
+
+	err := this.gc.MergePR(org, repo, number, sdk.PullRequestMergePutParam{
+		MergeMethod: cfg.MergeMethod,
+	})
+	if err != nil {
+		return this.skip(org, repo, number, fmt.Sprintf("failed to merge, %v", err), log)
+	}
+
+	log.Infof("Merged the pull request by %s.", cfg.MergeMethod)
+	return nil
+}
+
+// skip comments the reason why the pull request is skipped, unless the last
+// reason is the same or it is not open any more.
+func (this *merge) skip(org, repo string, number int, reason string, log *logrus.Entry) error {
+	// The pull request may be merged by the other event or Sync in the
+	// meantime, since Sync works on a list which may be stale.
+	pr, err := this.gc.GetGiteePullRequest(org, repo, number)
+	if err != nil {
+		return err
+	}
+	if pr.State != "open" {
+		log.Infof("Skip commenting, since the pull request is %s already.", pr.State)
+		return nil
+	}
+
+	log.Infof("Skip merging the pull request, since %s", reason)
+
+	msg := fmt.Sprintf(skipNotification, reason)
+
+	botName, err := this.gc.BotName()
+	if err != nil {
+		return err
+	}
+
+	comments, err := this.gc.ListPRComments(org, repo, number)
+	if err != nil {
+		return err
+	}
+
+	for i := len(comments) - 1; i >= 0; i-- {
+		c := &comments[i]
+		if c.User == nil || c.User.Login != botName || !skipNotificationRe.MatchString(c.Body) {
+			continue
+		}
+		if c.Body == msg {
+			return nil
+		}
+		break
+	}
+
+	return this.gc.CreatePRComment(org, repo, number, msg)
+}
+
+func (this *merge) orgRepoConfig(org, repo string) (*pluginConfig, error) {
+	cfg, err := this.pluginConfig(org, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := cfg.MergeFor(org, repo)
+	if pc == nil {
+		return nil, fmt.Errorf("no merge plugin config for this repo:%s/%s", org, repo)
+	}
+
+	return pc, nil
+}
+
+func (this *merge) pluginConfig(org, repo string) (*configuration, error) {
+	c := this.getPluginConfig(this.PluginName(), org, repo)
+	if c == nil {
+		return nil, fmt.Errorf("can't find the configuration")
+	}
+
+	c1, ok := c.(*configuration)
+	if !ok {
+		return nil, fmt.Errorf("can't convert to configuration")
+	}
+
+	return c1, nil
+}
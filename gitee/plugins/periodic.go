@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"strings"
+	"time"
+
+	"gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/test-infra/prow/interrupts"
+)
+
+// PeriodicPlugin is implemented by the plugin which also works on the repos
+// periodically, since some of the states it reacts to don't cause events.
+type PeriodicPlugin interface {
+	Plugin
+
+	// Sync works on the repo. enabled reports whether the plugin is enabled
+	// for the target branch of pull request.
+	Sync(org, repo string, enabled func(branch string) bool, log *logrus.Entry) error
+}
+
+type periodicClient interface {
+	GetRepos(org string) ([]gitee.Project, error)
+}
+
+// RunPeriodicPlugins syncs each repo enabling the plugins every period until
+// the process is interrupted. It doesn't block.
+func RunPeriodicPlugins(c *ConfigAgent, rc *RepoConfigCache, gc periodicClient, ps []PeriodicPlugin, period time.Duration) {
+	if len(ps) == 0 {
+		return
+	}
+
+	interrupts.TickLiteral(func() {
+		cfg := c.Config()
+		for _, p := range ps {
+			syncPlugin(cfg, rc, gc, p)
+		}
+	}, period)
+}
+
+func syncPlugin(cfg *Configurations, rc *RepoConfigCache, gc periodicClient, p PeriodicPlugin) {
+	name := p.PluginName()
+	l := logrus.WithField("plugin", name)
+
+	start := time.Now()
+	defer func() {
+		l.WithField("duration", time.Since(start).String()).Debug("Completed syncing plugin.")
+	}()
+
+	for _, fullName := range enabledRepos(cfg, gc, name, l) {
+		v := strings.SplitN(fullName, "/", 2)
+		org, repo := v[0], v[1]
+
+		enabled := func(branch string) bool {
+			ps := sets.NewString(cfg.PluginsFor(org, repo, branch)...)
+			return ps.Has(name) && !sets.NewString(rc.disabledPlugins(org, repo)...).Has(name)
+		}
+
+		rl := l.WithFields(logrus.Fields{"org": org, "repo": repo})
+		if err := p.Sync(org, repo, enabled, rl); err != nil {
+			rl.WithError(err).Error("Error syncing repo.")
+		}
+	}
+}
+
+// enabledRepos returns the repos enabling the plugin, in which the orgs are
+// expanded to their repos except the excluded ones.
+func enabledRepos(cfg *Configurations, gc periodicClient, name string, l *logrus.Entry) []string {
+	orgs, repos, orgExceptions := cfg.EnabledReposForPlugin(name)
+
+	r := sets.NewString(repos...)
+	for _, org := range orgs {
+		items, err := gc.GetRepos(org)
+		if err != nil {
+			l.WithError(err).Errorf("Getting repos in org: %s.", org)
+			continue
+		}
+
+		for _, item := range items {
+			if !orgExceptions[org].Has(item.FullName) {
+				r.Insert(item.FullName)
+			}
+		}
+	}
+	return r.List()
+}
@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"gitee.com/openeuler/go-gitee/gitee"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/pluginhelp"
 )
@@ -13,3 +14,15 @@ type Plugin interface {
 }
 
 type GetPluginConfig func(string) PluginConfig
+
+// MergeChecker returns the reason if the pull request must not be merged,
+// or empty if it can be merged. Every path merging pull requests checks it.
+type MergeChecker func(org, repo string, pr *gitee.PullRequest) string
+
+// EnabledReposChecker is implemented by the plugin config which requires
+// the configuration for each org or repo enabling the plugin.
+type EnabledReposChecker interface {
+	// CheckEnabledRepos returns an error if any of the orgs and repos
+	// which enable the plugin misses the required configuration.
+	CheckEnabledRepos(orgs, repos []string) error
+}
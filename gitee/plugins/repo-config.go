@@ -0,0 +1,348 @@
+package plugins
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// RepoConfigFile is the file in the default branch of repo, by which the
+// maintainers of repo can tune the plugins without changing the central config.
+const RepoConfigFile = ".yabot.yaml"
+
+// RepoConfig is the content of RepoConfigFile.
+type RepoConfig struct {
+	// DisabledPlugins are the plugins which are enabled for the org and
+	// disabled for the repo. Only the plugins in DisablablePlugins of
+	// RepoConfigPolicy can be disabled, and the plugins enabled for the
+	// repo itself in central config are kept.
+	DisabledPlugins []string `json:"disabled_plugins,omitempty"`
+	// Plugins maps the plugin name to its settings which override the ones
+	// of central config. Only the plugins whose config implements
+	// RepoConfigOverrider can be overridden.
+	Plugins map[string]json.RawMessage `json:"plugins,omitempty"`
+}
+
+// RepoConfigPolicy is set in central config by the operators to limit what
+// RepoConfigFile of repos can change.
+type RepoConfigPolicy struct {
+	// DisablablePlugins are the plugins which the repos can disable by
+	// RepoConfigFile. No plugin can be disabled if empty.
+	DisablablePlugins []string `json:"disablable_plugins,omitempty"`
+}
+
+// RepoConfigOverrider is implemented by the plugin config whose settings can
+// be overridden by RepoConfigFile.
+type RepoConfigOverrider interface {
+	// OverrideFor returns the config for the repo, in which the settings are
+	// overridden by b, the JSON of plugin section of RepoConfigFile. It returns
+	// an error if b sets anything which is not allowed to be overridden.
+	OverrideFor(org, repo string, b []byte) (PluginConfig, error)
+}
+
+// GetRepoPluginConfig returns the config of plugin for the repo.
+type GetRepoPluginConfig func(name, org, repo string) PluginConfig
+
+type repoConfigClient interface {
+	GetGiteeRepo(org, repo string) (gitee.Project, error)
+	GetRef(org, repo, ref string) (string, error)
+	GetPathContent(org, repo, path, ref string) (gitee.Content, error)
+}
+
+// repoConfigRetryInterval is how long to wait before loading RepoConfigFile
+// of repo again after it failed, so that the events during an outage of Gitee
+// don't keep calling its API.
+const repoConfigRetryInterval = time.Minute
+
+type repoConfigEntry struct {
+	sha string
+	// loaded is false if RepoConfigFile needs to be loaded at sha.
+	loaded bool
+	rc     *RepoConfig
+	// gen is increased when RepoConfigFile is changed by a push, so that
+	// the result of loading in progress is known to be outdated.
+	gen int
+	// loading is closed when the loading in progress is done.
+	loading chan struct{}
+	// failedAt is when the last loading failed.
+	failedAt time.Time
+}
+
+// RepoConfigCache caches RepoConfigFile of repos by the commit of default
+// branch. The file is reloaded lazily after a push to the default branch
+// changes it.
+type RepoConfigCache struct {
+	c  *ConfigAgent
+	gc repoConfigClient
+
+	// mut only guards repos. The files are loaded without holding it.
+	mut   sync.Mutex
+	repos map[string]*repoConfigEntry
+}
+
+// NewRepoConfigCache returns a RepoConfigCache.
+func NewRepoConfigCache(c *ConfigAgent, gc repoConfigClient) *RepoConfigCache {
+	return &RepoConfigCache{
+		c:     c,
+		gc:    gc,
+		repos: map[string]*repoConfigEntry{},
+	}
+}
+
+// RepoConfig returns RepoConfigFile of repo. It is nil if the repo doesn't
+// have the file or it is invalid. Only one of the concurrent calls for the
+// same repo loads the file, and the others wait for it.
+func (rc *RepoConfigCache) RepoConfig(org, repo string) *RepoConfig {
+	if rc == nil {
+		return nil
+	}
+
+	e, sha, gen, done := rc.startLoading(org + "/" + repo)
+	if done == nil {
+		return rc.entryConfig(e)
+	}
+
+	sha, v, retry := rc.fetch(org, repo, sha)
+
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
+
+	e.loading = nil
+	close(done)
+
+	if retry {
+		// The last known file is kept until it can be loaded again.
+		e.failedAt = time.Now()
+		return e.rc
+	}
+
+	e.rc = v
+	if e.sha == "" {
+		e.sha = sha
+	}
+	// The invalid file is not reloaded until it is changed.
+	e.loaded = e.gen == gen
+	return e.rc
+}
+
+// startLoading returns the entry of repo. If the entry needs to be loaded
+// and no one else is loading it, it marks the entry as being loaded and
+// returns the sha to load at, the generation of entry and the channel to
+// close when done. Otherwise, it waits for the loading in progress if any,
+// and the returned channel is nil.
+func (rc *RepoConfigCache) startLoading(key string) (*repoConfigEntry, string, int, chan struct{}) {
+	rc.mut.Lock()
+
+	e, ok := rc.repos[key]
+	if !ok {
+		e = &repoConfigEntry{}
+		rc.repos[key] = e
+	}
+
+	if ch := e.loading; ch != nil {
+		rc.mut.Unlock()
+		<-ch
+		return e, "", 0, nil
+	}
+
+	if e.loaded || time.Since(e.failedAt) < repoConfigRetryInterval {
+		rc.mut.Unlock()
+		return e, "", 0, nil
+	}
+
+	done := make(chan struct{})
+	e.loading = done
+	sha, gen := e.sha, e.gen
+	rc.mut.Unlock()
+
+	return e, sha, gen, done
+}
+
+func (rc *RepoConfigCache) entryConfig(e *repoConfigEntry) *RepoConfig {
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
+
+	return e.rc
+}
+
+// fetch loads RepoConfigFile at sha, which is the commit of default branch
+// if empty. It returns the sha and whether to retry if it fails.
+func (rc *RepoConfigCache) fetch(org, repo, sha string) (string, *RepoConfig, bool) {
+	l := logrus.WithFields(logrus.Fields{"org": org, "repo": repo})
+
+	if sha == "" {
+		s, err := rc.defaultBranchSHA(org, repo)
+		if err != nil {
+			l.WithError(err).Warn("Failed to get the default branch of repo.")
+			return "", nil, true
+		}
+		sha = s
+	}
+
+	v, retry, err := rc.load(org, repo, sha)
+	if err != nil {
+		l.WithError(err).Warnf("Failed to load %s.", RepoConfigFile)
+	}
+	return sha, v, retry
+}
+
+func (rc *RepoConfigCache) defaultBranchSHA(org, repo string) (string, error) {
+	p, err := rc.gc.GetGiteeRepo(org, repo)
+	if err != nil {
+		return "", err
+	}
+	return rc.gc.GetRef(org, repo, "heads/"+p.DefaultBranch)
+}
+
+// load loads RepoConfigFile at sha. It also returns whether to retry if it fails.
+func (rc *RepoConfigCache) load(org, repo, sha string) (*RepoConfig, bool, error) {
+	c, err := rc.gc.GetPathContent(org, repo, RepoConfigFile, sha)
+	if err != nil {
+		return nil, true, err
+	}
+	if c.Content == "" {
+		return nil, false, nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(c.Content)
+	if err != nil {
+		return nil, false, err
+	}
+
+	v := new(RepoConfig)
+	if err := yaml.Unmarshal(b, v); err != nil {
+		return nil, false, err
+	}
+	return v, false, nil
+}
+
+// handlePushEvent records the new commit of default branch, and marks
+// RepoConfigFile to be reloaded if the push changes it.
+func (rc *RepoConfigCache) handlePushEvent(e *gitee.PushEvent) {
+	if rc == nil || e.Repository == nil {
+		return
+	}
+
+	if strings.TrimPrefix(strOf(e.Ref), "refs/heads/") != e.Repository.DefaultBranch {
+		return
+	}
+
+	key := e.Repository.Namespace + "/" + e.Repository.Path
+
+	rc.mut.Lock()
+	defer rc.mut.Unlock()
+
+	entry, ok := rc.repos[key]
+	if !ok {
+		return
+	}
+
+	entry.sha = strOf(e.After)
+	if changesRepoConfig(e) {
+		entry.loaded = false
+		entry.gen++
+		entry.failedAt = time.Time{}
+	}
+}
+
+// changesRepoConfig reports whether the push may change RepoConfigFile. The
+// commits in push event are truncated, so it is true if not all of them are
+// included.
+func changesRepoConfig(e *gitee.PushEvent) bool {
+	if len(e.Commits) == 0 || int(e.TotalCommitsCount) > len(e.Commits) {
+		return true
+	}
+
+	for _, c := range e.Commits {
+		for _, files := range [][]string{c.Added, c.Modified, c.Removed} {
+			for _, f := range files {
+				if f == RepoConfigFile {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// disabledPlugins returns the plugins disabled by RepoConfigFile of repo,
+// which are allowed by RepoConfigPolicy and not enabled for the repo itself.
+func (rc *RepoConfigCache) disabledPlugins(org, repo string) []string {
+	c := rc.c.Config()
+	if c == nil || len(c.RepoConfig.DisablablePlugins) == 0 {
+		return nil
+	}
+
+	v := rc.RepoConfig(org, repo)
+	if v == nil || len(v.DisabledPlugins) == 0 {
+		return nil
+	}
+
+	fullName := org + "/" + repo
+	allowed := sets.NewString(c.RepoConfig.DisablablePlugins...)
+	own := sets.NewString(c.Plugins[fullName].Names()...)
+	for _, p := range c.ExternalPlugins[fullName] {
+		own.Insert(p.Name)
+	}
+
+	var r []string
+	for _, p := range v.DisabledPlugins {
+		if allowed.Has(p) && !own.Has(p) {
+			r = append(r, p)
+		} else {
+			logrus.WithFields(logrus.Fields{"org": org, "repo": repo, "plugin": p}).Debugf("The plugin can't be disabled by %s.", RepoConfigFile)
+		}
+	}
+	return r
+}
+
+// PluginConfig returns the config of plugin for the repo, in which the
+// settings are overridden by RepoConfigFile of repo if allowed. It can be
+// passed to the constructor of plugin as GetRepoPluginConfig.
+func (rc *RepoConfigCache) PluginConfig(name, org, repo string) PluginConfig {
+	c := rc.c.Config()
+	if c == nil {
+		return nil
+	}
+
+	pc := c.GetPluginConfig(name)
+	if pc == nil {
+		return nil
+	}
+
+	// RepoConfigFile is not loaded if it can't override the config anyway.
+	o, ok := pc.(RepoConfigOverrider)
+	if !ok {
+		return pc
+	}
+
+	v := rc.RepoConfig(org, repo)
+	if v == nil {
+		return pc
+	}
+
+	b, ok := v.Plugins[name]
+	if !ok {
+		return pc
+	}
+
+	l := logrus.WithFields(logrus.Fields{"org": org, "repo": repo, "plugin": name})
+
+	r, err := o.OverrideFor(org, repo, b)
+	if err == nil {
+		r.SetDefault()
+		err = r.Validate()
+	}
+	if err != nil {
+		l.WithError(err).Warnf("Invalid overrides in %s.", RepoConfigFile)
+		return pc
+	}
+	return r
+}
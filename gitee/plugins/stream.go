@@ -0,0 +1,232 @@
+package plugins
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// DeliveryPush means the events are posted to the endpoint of external plugin.
+	DeliveryPush = "push"
+	// DeliveryStream means the external plugin pulls the events from the event stream.
+	DeliveryStream = "stream"
+
+	streamHeartbeatPeriod = 30 * time.Second
+)
+
+// StreamEvent is an event sent to the external plugins subscribing the event stream.
+type StreamEvent struct {
+	ID        uint64          `json:"id"`
+	EventType string          `json:"event_type"`
+	EventGUID string          `json:"event_guid"`
+	Header    http.Header     `json:"header"`
+	Payload   json.RawMessage `json:"payload"`
+
+	plugins sets.String
+}
+
+// EventStream keeps the latest events which need to be delivered to the external
+// plugins by stream, and serves them as Server-Sent Events. Each event has an
+// increasing id which an external plugin can use as the cursor to resume from
+// after it is disconnected.
+type EventStream struct {
+	c    *ConfigAgent
+	size int
+
+	mut    sync.Mutex
+	events []*StreamEvent
+	nextID uint64
+	// notify is closed when a new event is published.
+	notify chan struct{}
+}
+
+// NewEventStream returns an EventStream which keeps at most size events.
+func NewEventStream(c *ConfigAgent, size int) *EventStream {
+	return &EventStream{
+		c:      c,
+		size:   size,
+		nextID: 1,
+		notify: make(chan struct{}),
+	}
+}
+
+func (s *EventStream) publish(plugins []string, eventType, eventGUID string, payload []byte, h http.Header) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.events = append(s.events, &StreamEvent{
+		ID:        s.nextID,
+		EventType: eventType,
+		EventGUID: eventGUID,
+		Header:    h,
+		Payload:   payload,
+		plugins:   sets.NewString(plugins...),
+	})
+	s.nextID++
+
+	if n := len(s.events) - s.size; n > 0 {
+		s.events = s.events[n:]
+	}
+
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// since returns the events for the plugin after the cursor, whether some of the
+// events may have been dropped, the id of latest event and a channel to wait for
+// the new events.
+func (s *EventStream) since(plugin string, cursor uint64) ([]*StreamEvent, bool, uint64, <-chan struct{}) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	gap := len(s.events) > 0 && cursor+1 < s.events[0].ID
+
+	var r []*StreamEvent
+	for _, e := range s.events {
+		if e.ID > cursor && e.plugins.Has(plugin) {
+			r = append(r, e)
+		}
+	}
+	return r, gap, s.nextID - 1, s.notify
+}
+
+func (s *EventStream) latestID() uint64 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.nextID - 1
+}
+
+// ServeHTTP streams the events to the external plugin specified by the query
+// parameter "plugin". The request must carry the secret shared with the plugin
+// as a bearer token. The cursor is read from the "Last-Event-ID" header or the
+// query parameter "cursor". Only the new events are sent if neither is set.
+func (s *EventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "405 Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	plugin := r.URL.Query().Get("plugin")
+	if err := s.authenticate(plugin, r); err != nil {
+		logrus.WithField("external-plugin", plugin).WithError(err).Warn("Unauthorized subscription of event stream.")
+		http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	cursor, err := parseCursor(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("400 Bad Request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if cursor == nil {
+		v := s.latestID()
+		cursor = &v
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "500 Internal server error: streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	l := logrus.WithField("external-plugin", plugin)
+	l.Info("External plugin subscribed the event stream.")
+
+	heartbeat := time.NewTicker(streamHeartbeatPeriod)
+	defer heartbeat.Stop()
+
+	c := *cursor
+	for {
+		events, gap, latest, wait := s.since(plugin, c)
+		if gap {
+			fmt.Fprintf(w, "event: gap\ndata: {\"cursor\":%d}\n\n", c)
+		}
+
+		for _, e := range events {
+			b, err := json.Marshal(e)
+			if err != nil {
+				l.WithError(err).Error("Error marshaling stream event.")
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.EventType, b)
+		}
+		if gap || len(events) > 0 {
+			flusher.Flush()
+		}
+		c = latest
+
+		select {
+		case <-r.Context().Done():
+			l.Info("External plugin unsubscribed the event stream.")
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-wait:
+		}
+	}
+}
+
+func (s *EventStream) authenticate(plugin string, r *http.Request) error {
+	if plugin == "" {
+		return fmt.Errorf("missing plugin")
+	}
+
+	p := s.c.Config().streamPlugin(plugin)
+	if p == nil {
+		return fmt.Errorf("plugin %s is not configured to deliver by stream", plugin)
+	}
+
+	b, err := ioutil.ReadFile(p.HMACSecretFile)
+	if err != nil {
+		return err
+	}
+	secret := strings.TrimSpace(string(b))
+	if secret == "" {
+		return fmt.Errorf("the secret of plugin %s is empty", plugin)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return fmt.Errorf("missing bearer token")
+	}
+
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return fmt.Errorf("invalid token")
+	}
+	return nil
+}
+
+func parseCursor(r *http.Request) (*uint64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("cursor")
+	}
+	if v == "" {
+		return nil, nil
+	}
+
+	c, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor %q", v)
+	}
+	return &c, nil
+}
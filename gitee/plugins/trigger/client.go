@@ -0,0 +1,24 @@
+package trigger
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/github"
+)
+
+type giteeClient interface {
+	IsMember(org, login string) (bool, error)
+	IsCollaborator(owner, repo, login string) (bool, error)
+	ListCollaborators(org, repo string) ([]github.User, error)
+	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+	AddPRLabel(owner, repo string, number int, label string) error
+	RemovePRLabel(owner, repo string, number int, label string) error
+	CreatePRComment(owner, repo string, number int, comment string) error
+}
+
+// prowJobClient is the part of ProwJob clientset used by trigger, which
+// is implemented by both the real and the fake clientsets.
+type prowJobClient interface {
+	Create(*prowapi.ProwJob) (*prowapi.ProwJob, error)
+	List(opts metav1.ListOptions) (*prowapi.ProwJobList, error)
+}
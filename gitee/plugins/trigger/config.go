@@ -0,0 +1,41 @@
+package trigger
+
+import (
+	"github.com/huaweicloud/golangsdk"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+type configuration struct {
+	Trigger []pluginConfig `json:"trigger,omitempty"`
+}
+
+func (c *configuration) Validate() error {
+	_, err := golangsdk.BuildRequestBody(c, "")
+	return err
+}
+
+func (c *configuration) SetDefault() {
+}
+
+// TriggerFor returns the config for the repo. The config of repo takes
+// precedence over the one of its org. It is nil if neither is set.
+func (c *configuration) TriggerFor(org, repo string) *pluginConfig {
+	i := plugins.FindConfig(org, repo, len(c.Trigger), func(i int) []string {
+		return c.Trigger[i].Repos
+	})
+	if i < 0 {
+		return nil
+	}
+	return &(c.Trigger[i])
+}
+
+type pluginConfig struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos" required:"true"`
+
+	// TrustedUsers are trusted besides the members of org and the
+	// collaborators of repo. The pull requests of untrusted users are not
+	// tested until a trusted user comments /ok-to-test.
+	TrustedUsers []string `json:"trusted_users,omitempty"`
+}
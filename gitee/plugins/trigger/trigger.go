@@ -0,0 +1,450 @@
+package trigger
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/kube"
+	"k8s.io/test-infra/prow/labels"
+	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/pluginhelp"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+	originp "github.com/opensourceways/yabot/prow/plugins"
+)
+
+const pluginName = "trigger"
+
+var testRe = regexp.MustCompile(`(?m)^/test\s+(\S+)`)
+
+type trigger struct {
+	getPluginConfig plugins.GetRepoPluginConfig
+	getProwConfig   prowConfig.Getter
+	gitClient       git.ClientFactory
+	gc              giteeClient
+	pjc             prowJobClient
+}
+
+// NewTrigger returns the trigger plugin which creates the ProwJobs of the
+// presubmits in prow job config for the pull requests. The pull requests of
+// untrusted users are tested after a trusted user comments /ok-to-test.
+func NewTrigger(f plugins.GetRepoPluginConfig, cfg prowConfig.Getter, gitClient git.ClientFactory, gc giteeClient, pjc prowJobClient) plugins.Plugin {
+	return &trigger{
+		getPluginConfig: f,
+		getProwConfig:   cfg,
+		gitClient:       gitClient,
+		gc:              gc,
+		pjc:             pjc,
+	}
+}
+
+func (this *trigger) HelpProvider(_ []prowConfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	pluginHelp := &pluginhelp.PluginHelp{
+		Description: "The trigger plugin starts tests in reaction to commands and pull request events. It creates the ProwJobs of the presubmits which are configured for the repository when a pull request is opened, its source branch or target branch is changed, or a test command is commented. The pull requests of the users who are not the members of org, the collaborators of repository or the trusted users are labeled with '" + labels.NeedsOkToTest + "', and not tested until a trusted user comments '/ok-to-test'. The new commits of them need '/ok-to-test' again.",
+	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/ok-to-test",
+		Description: "Marks a PR as 'trusted' and starts tests.",
+		Featured:    false,
+		WhoCanUse:   "Members of the organization, collaborators of the repository and the trusted users.",
+		Examples:    []string{"/ok-to-test"},
+	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/test (<job name>|all)",
+		Description: "Manually starts a/all automatically triggered test job(s). Lists all possible job(s) when no jobs/an invalid job are specified.",
+		Featured:    true,
+		WhoCanUse:   "Anyone can trigger this command on a trusted PR, but only trusted users can trigger it on an untrusted PR.",
+		Examples:    []string{"/test all", "/test pull-bazel-test"},
+	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/retest",
+		Description: "Rerun test jobs that have failed.",
+		Featured:    true,
+		WhoCanUse:   "Anyone can trigger this command on a trusted PR, but only trusted users can trigger it on an untrusted PR.",
+		Examples:    []string{"/retest"},
+	})
+	return pluginHelp, nil
+}
+
+func (this *trigger) PluginName() string {
+	return pluginName
+}
+
+func (this *trigger) NewPluginConfig() plugins.PluginConfig {
+	return &configuration{}
+}
+
+func (this *trigger) RegisterEventHandler(p plugins.Plugins) {
+	name := this.PluginName()
+	p.RegisterNoteEventHandler(name, this.handleNoteEvent)
+	p.RegisterPullRequestHandler(name, this.handlePullRequestEvent)
+}
+
+func (this *trigger) handlePullRequestEvent(e *sdk.PullRequestEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handlePullRequest")
+	}()
+
+	if e.PullRequest.State != "open" {
+		log.Debug("Pull request state is not open, skipping...")
+		return nil
+	}
+
+	action := plugins.ConvertPullRequestAction(e)
+	switch action {
+	case github.PullRequestActionOpened, github.PullRequestActionSynchronize, github.PullRequestActionEdited:
+	default:
+		return nil
+	}
+
+	pr := e.PullRequest
+	org, repo, number := pr.Base.Repo.Namespace, pr.Base.Repo.Path, int(pr.Number)
+	needsOkToTest := hasLabel(pr.Labels, labels.NeedsOkToTest)
+
+	author := ""
+	if pr.User != nil {
+		author = pr.User.Login
+	}
+	trusted, err := this.isTrusted(org, repo, author)
+	if err != nil {
+		// It fails closed, so that the pull request is not left without
+		// either the label or the tests.
+		log.WithError(err).Warningf("Failed to check whether %s is trusted, regarding as untrusted.", author)
+	}
+
+	if !trusted {
+		// Changing the target branch keeps the commits which are ok to
+		// test, but the new commits must be checked again.
+		if action == github.PullRequestActionEdited && !needsOkToTest {
+			return this.runAll(pr, log)
+		}
+		if needsOkToTest {
+			return nil
+		}
+
+		log.Infof("Pull request of untrusted user %s needs ok-to-test.", author)
+		if err := this.gc.AddPRLabel(org, repo, number, labels.NeedsOkToTest); err != nil {
+			return err
+		}
+		return this.gc.CreatePRComment(org, repo, number, needsOkToTestMessage(author, org))
+	}
+
+	// The author is trusted now, such as joining the org.
+	if needsOkToTest {
+		if err := this.gc.RemovePRLabel(org, repo, number, labels.NeedsOkToTest); err != nil {
+			log.WithError(err).Warningf("Could not remove %s label.", labels.NeedsOkToTest)
+		}
+	}
+
+	return this.runAll(pr, log)
+}
+
+// runAll runs the presubmits which are triggered automatically.
+func (this *trigger) runAll(pr *sdk.PullRequestHook, log *logrus.Entry) error {
+	presubmits, err := this.presubmits(pr)
+	if err != nil {
+		return err
+	}
+
+	return this.runPresubmits(pr, pjutil.TestAllFilter(), presubmits, log)
+}
+
+func (this *trigger) handleNoteEvent(e *sdk.NoteEvent, log *logrus.Entry) error {
+	funcStart := time.Now()
+	defer func() {
+		log.WithField("duration", time.Since(funcStart).String()).Debug("Completed handleNoteEvent")
+	}()
+
+	if *(e.Action) != "comment" {
+		log.Debug("Event is not a creation of a comment, skipping.")
+		return nil
+	}
+
+	if *(e.NoteableType) != "PullRequest" || e.PullRequest.State != "open" {
+		return nil
+	}
+
+	pr := e.PullRequest
+	body := e.Comment.Body
+
+	org, repo, number := pr.Base.Repo.Namespace, pr.Base.Repo.Path, int(pr.Number)
+	commenter := e.Comment.User.Login
+	reply := func(resp string) error {
+		msg := originp.FormatResponseRaw(body, e.Comment.HtmlUrl, commenter, resp)
+		return this.gc.CreatePRComment(org, repo, number, msg)
+	}
+
+	okToTest := pjutil.OkToTestRe.MatchString(body)
+	testAll := okToTest || pjutil.RetestRe.MatchString(body) || pjutil.TestAllRe.MatchString(body)
+	isTest := testRe.MatchString(body)
+
+	// Getting the presubmits may clone the repo for inrepoconfig, so the
+	// comments without any test command are skipped before it.
+	static := this.getProwConfig().PresubmitsStatic[repoIdentifier(pr)]
+	if !testAll && !isTest && !triggerMatches(static, body) {
+		return nil
+	}
+
+	presubmits, err := this.presubmits(pr)
+	if err != nil {
+		return err
+	}
+
+	if !testAll && !triggerMatches(presubmits, body) {
+		if !isTest {
+			return nil
+		}
+
+		// It is a "/test" command of unknown jobs.
+		return reply(unknownJobsResponse(presubmits, pr.Base.Ref))
+	}
+
+	// The trust of commenter matters only if the pull request needs
+	// ok-to-test, or the command is "/ok-to-test".
+	needsOkToTest := hasLabel(pr.Labels, labels.NeedsOkToTest)
+	if okToTest || needsOkToTest {
+		trusted, err := this.isTrusted(org, repo, commenter)
+		if err != nil {
+			return err
+		}
+
+		if okToTest {
+			if !trusted {
+				return reply("Only the members of org, the collaborators of repository and the trusted users can use `/ok-to-test`.")
+			}
+
+			if needsOkToTest {
+				if err := this.gc.RemovePRLabel(org, repo, number, labels.NeedsOkToTest); err != nil {
+					log.WithError(err).Warningf("Could not remove %s label.", labels.NeedsOkToTest)
+				}
+			}
+		} else if !trusted {
+			log.Infof("Untrusted user %s can't test the pull request which needs ok-to-test.", commenter)
+			return nil
+		}
+	}
+
+	contexts := func() (sets.String, sets.String, error) {
+		return this.jobContexts(pr)
+	}
+	filter, err := pjutil.PresubmitFilter(okToTest, contexts, body, log)
+	if err != nil {
+		return err
+	}
+
+	return this.runPresubmits(pr, filter, presubmits, log)
+}
+
+// isTrusted checks whether the user is a member of org, a collaborator of
+// repo or a trusted user. The error is returned only if none of them can be
+// confirmed and any check fails.
+func (this *trigger) isTrusted(org, repo, user string) (bool, error) {
+	if user == "" {
+		return false, nil
+	}
+
+	cfg, err := this.pluginConfig(org, repo)
+	if err != nil {
+		return false, err
+	}
+	if pc := cfg.TriggerFor(org, repo); pc != nil {
+		for _, u := range pc.TrustedUsers {
+			if github.NormLogin(u) == github.NormLogin(user) {
+				return true, nil
+			}
+		}
+	}
+
+	isMember, memberErr := this.gc.IsMember(org, user)
+	if isMember {
+		return true, nil
+	}
+
+	b, err := plugins.IsCollaborator(this.gc, org, repo, user)
+	if err != nil || b {
+		return b, err
+	}
+	return false, memberErr
+}
+
+func (this *trigger) pluginConfig(org, repo string) (*configuration, error) {
+	c := this.getPluginConfig(this.PluginName(), org, repo)
+	if c == nil {
+		return nil, fmt.Errorf("can't find the configuration")
+	}
+
+	c1, ok := c.(*configuration)
+	if !ok {
+		return nil, fmt.Errorf("can't convert to configuration")
+	}
+
+	return c1, nil
+}
+
+// presubmits returns the presubmits of repo, including the ones in the repo
+// if inrepoconfig is enabled.
+func (this *trigger) presubmits(pr *sdk.PullRequestHook) ([]prowConfig.Presubmit, error) {
+	baseSHA := func() (string, error) {
+		return pr.Base.Sha, nil
+	}
+	headSHA := func() (string, error) {
+		return pr.Head.Sha, nil
+	}
+
+	return this.getProwConfig().GetPresubmits(this.gitClient, repoIdentifier(pr), baseSHA, headSHA)
+}
+
+func repoIdentifier(pr *sdk.PullRequestHook) string {
+	return fmt.Sprintf("%s/%s", pr.Base.Repo.Namespace, pr.Base.Repo.Path)
+}
+
+// runPresubmits creates the ProwJobs of the presubmits selected by filter.
+func (this *trigger) runPresubmits(pr *sdk.PullRequestHook, filter pjutil.Filter, presubmits []prowConfig.Presubmit, log *logrus.Entry) error {
+	org, repo, number := pr.Base.Repo.Namespace, pr.Base.Repo.Path, int(pr.Number)
+
+	changes := func() ([]string, error) {
+		v, err := this.gc.GetPullRequestChanges(org, repo, number)
+		if err != nil {
+			return nil, err
+		}
+
+		r := make([]string, 0, len(v))
+		for _, item := range v {
+			r = append(r, item.Filename)
+		}
+		return r, nil
+	}
+
+	toTrigger, err := pjutil.FilterPresubmits(filter, changes, pr.Base.Ref, presubmits, log)
+	if err != nil {
+		return err
+	}
+
+	refs := createRefs(pr)
+	var errs []error
+	for _, job := range toTrigger {
+		pj := pjutil.NewProwJob(pjutil.PresubmitSpec(job, refs), job.Labels, job.Annotations)
+
+		l := log.WithFields(pjutil.ProwJobFields(&pj))
+		l.Info("Creating a new prowjob.")
+		if _, err := this.pjc.Create(&pj); err != nil {
+			l.WithError(err).Error("Failed to create prowjob.")
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// jobContexts returns the contexts of the failed jobs and all the jobs which
+// have run for the head of pull request. Gitee has no commit statuses, so
+// they are got from the latest ProwJobs.
+func (this *trigger) jobContexts(pr *sdk.PullRequestHook) (sets.String, sets.String, error) {
+	selector := k8slabels.Set{
+		kube.OrgLabel:  pr.Base.Repo.Namespace,
+		kube.RepoLabel: pr.Base.Repo.Path,
+		kube.PullLabel: strconv.Itoa(int(pr.Number)),
+	}
+	pjs, err := this.pjc.List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var items []prowapi.ProwJob
+	for _, pj := range pjs.Items {
+		if refs := pj.Spec.Refs; refs != nil && len(refs.Pulls) > 0 && refs.Pulls[0].SHA == pr.Head.Sha {
+			items = append(items, pj)
+		}
+	}
+
+	failed := sets.NewString()
+	all := sets.NewString()
+	for _, pj := range pjutil.GetLatestProwJobs(items, prowapi.PresubmitJob) {
+		all.Insert(pj.Spec.Context)
+
+		if s := pj.Status.State; s == prowapi.FailureState || s == prowapi.ErrorState {
+			failed.Insert(pj.Spec.Context)
+		}
+	}
+	return failed, all, nil
+}
+
+// createRefs translates the refs of gitee pull request.
+func createRefs(pr *sdk.PullRequestHook) prowapi.Refs {
+	repoLink := pr.Base.Repo.HtmlUrl
+	number := int(pr.Number)
+
+	pull := prowapi.Pull{
+		Number:     number,
+		SHA:        pr.Head.Sha,
+		Ref:        pr.Head.Ref,
+		Link:       pr.HtmlUrl,
+		CommitLink: fmt.Sprintf("%s/pulls/%d/commits", repoLink, number),
+	}
+	if u := pr.User; u != nil {
+		pull.Author = u.Login
+		pull.AuthorLink = u.HtmlUrl
+	}
+
+	return prowapi.Refs{
+		Org:      pr.Base.Repo.Namespace,
+		Repo:     pr.Base.Repo.Path,
+		RepoLink: repoLink,
+		BaseRef:  pr.Base.Ref,
+		BaseSHA:  pr.Base.Sha,
+		BaseLink: fmt.Sprintf("%s/commit/%s", repoLink, pr.Base.Sha),
+		Pulls:    []prowapi.Pull{pull},
+	}
+}
+
+func hasLabel(ls []sdk.LabelHook, label string) bool {
+	for _, l := range ls {
+		if l.Name == label {
+			return true
+		}
+	}
+	return false
+}
+
+func needsOkToTestMessage(author, org string) string {
+	return fmt.Sprintf("Hi @%s. Thanks for your PR.\n\nI'm waiting for a member of %s to verify that this patch is reasonable to test. If it is, they should reply with `/ok-to-test` on its own line. Until that is done, I will not automatically test the new commits in this PR, but the usual testing commands by the trusted users will still work.\n\nOnce the patch is verified, the new status will be reflected by the `%s` label being removed.", author, org, labels.NeedsOkToTest)
+}
+
+func triggerMatches(presubmits []prowConfig.Presubmit, body string) bool {
+	for _, job := range presubmits {
+		if job.TriggerMatches(body) {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownJobsResponse lists the commands of the jobs which can run against
+// the branch.
+func unknownJobsResponse(presubmits []prowConfig.Presubmit, branch string) string {
+	cmds := sets.NewString()
+	for _, job := range presubmits {
+		if job.CouldRun(branch) && job.RerunCommand != "" {
+			cmds.Insert("`" + job.RerunCommand + "`")
+		}
+	}
+
+	if cmds.Len() == 0 {
+		return "No presubmit jobs are available for this pull request."
+	}
+
+	return fmt.Sprintf("The specified target(s) for `/test` were not found.\nThe following commands are available to trigger jobs:\n* %s", strings.Join(cmds.List(), "\n* "))
+}
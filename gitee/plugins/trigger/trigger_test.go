@@ -0,0 +1,487 @@
+package trigger
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/client/clientset/versioned/fake"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/labels"
+	"k8s.io/test-infra/prow/pjutil"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+const (
+	testOrg       = "org"
+	testRepo      = "repo"
+	testNumber    = 5
+	testHeadSHA   = "head-sha"
+	testNamespace = "prowjobs"
+)
+
+type fakeGiteeClient struct {
+	members       sets.String
+	collaborators sets.String
+	// collaboratorErr fails checking the collaborator directly, and
+	// listErr fails listing the collaborators.
+	collaboratorErr bool
+	listErr         bool
+
+	added    []string
+	removed  []string
+	comments []string
+}
+
+func (f *fakeGiteeClient) IsMember(org, login string) (bool, error) {
+	return f.members.Has(login), nil
+}
+
+func (f *fakeGiteeClient) IsCollaborator(owner, repo, login string) (bool, error) {
+	if f.collaboratorErr {
+		return false, errors.New("failed to check collaborator")
+	}
+	return f.collaborators.Has(login), nil
+}
+
+func (f *fakeGiteeClient) ListCollaborators(org, repo string) ([]github.User, error) {
+	if f.listErr {
+		return nil, errors.New("failed to list collaborators")
+	}
+
+	var r []github.User
+	for _, u := range f.collaborators.List() {
+		r = append(r, github.User{Login: u})
+	}
+	return r, nil
+}
+
+func (f *fakeGiteeClient) GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error) {
+	return []github.PullRequestChange{{Filename: "main.go"}}, nil
+}
+
+func (f *fakeGiteeClient) AddPRLabel(owner, repo string, number int, label string) error {
+	f.added = append(f.added, label)
+	return nil
+}
+
+func (f *fakeGiteeClient) RemovePRLabel(owner, repo string, number int, label string) error {
+	f.removed = append(f.removed, label)
+	return nil
+}
+
+func (f *fakeGiteeClient) CreatePRComment(owner, repo string, number int, comment string) error {
+	f.comments = append(f.comments, comment)
+	return nil
+}
+
+func testPresubmits(t *testing.T) []prowConfig.Presubmit {
+	var ps []prowConfig.Presubmit
+	for _, job := range []struct {
+		name      string
+		alwaysRun bool
+	}{
+		{name: "job-a", alwaysRun: true},
+		{name: "job-b", alwaysRun: true},
+		{name: "job-c"},
+	} {
+		ps = append(ps, prowConfig.Presubmit{
+			JobBase: prowConfig.JobBase{
+				Name:  job.name,
+				Agent: string(prowapi.KubernetesAgent),
+			},
+			AlwaysRun:    job.alwaysRun,
+			Reporter:     prowConfig.Reporter{Context: job.name},
+			Trigger:      prowConfig.DefaultTriggerFor(job.name),
+			RerunCommand: prowConfig.DefaultRerunCommandFor(job.name),
+		})
+	}
+
+	if err := prowConfig.SetPresubmitRegexes(ps); err != nil {
+		t.Fatalf("Setting the regexes of presubmits: %v", err)
+	}
+	return ps
+}
+
+func makePR(author string, prLabels ...string) *sdk.PullRequestHook {
+	repo := &sdk.ProjectHook{
+		Namespace: testOrg,
+		Path:      testRepo,
+		HtmlUrl:   "https://gitee.com/org/repo",
+	}
+
+	pr := &sdk.PullRequestHook{
+		Number:  testNumber,
+		State:   "open",
+		HtmlUrl: "https://gitee.com/org/repo/pulls/5",
+		User: &sdk.UserHook{
+			Login:   author,
+			HtmlUrl: "https://gitee.com/" + author,
+		},
+		Head: &sdk.BranchHook{Ref: "feature", Sha: testHeadSHA},
+		Base: &sdk.BranchHook{Ref: "master", Sha: "base-sha", Repo: repo},
+	}
+	for _, l := range prLabels {
+		pr.Labels = append(pr.Labels, sdk.LabelHook{Name: l})
+	}
+	return pr
+}
+
+// makeProwJob returns a ProwJob of the presubmit which has run for the pull
+// request with the state.
+func makeProwJob(job prowConfig.Presubmit, pr *sdk.PullRequestHook, state prowapi.ProwJobState) *prowapi.ProwJob {
+	pj := pjutil.NewProwJob(pjutil.PresubmitSpec(job, createRefs(pr)), nil, nil)
+	pj.Namespace = testNamespace
+	pj.Status.State = state
+	return &pj
+}
+
+func newTestTrigger(t *testing.T, gc *fakeGiteeClient, trustedUsers []string, objs ...runtime.Object) (*trigger, *fake.Clientset) {
+	c := &prowConfig.Config{
+		JobConfig: prowConfig.JobConfig{
+			PresubmitsStatic: map[string][]prowConfig.Presubmit{
+				testOrg + "/" + testRepo: testPresubmits(t),
+			},
+		},
+	}
+	cfg := &configuration{
+		Trigger: []pluginConfig{{
+			Repos:        []string{testOrg},
+			TrustedUsers: trustedUsers,
+		}},
+	}
+
+	pjc := fake.NewSimpleClientset(objs...)
+	p := NewTrigger(
+		func(string, string, string) plugins.PluginConfig { return cfg },
+		func() *prowConfig.Config { return c },
+		nil, gc, pjc.ProwV1().ProwJobs(testNamespace),
+	)
+	return p.(*trigger), pjc
+}
+
+// createdJobs returns the names of jobs created for the head of pull request.
+func createdJobs(t *testing.T, pjc *fake.Clientset, existing int) []string {
+	pjs, err := pjc.ProwV1().ProwJobs(testNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Listing ProwJobs: %v", err)
+	}
+
+	var r []string
+	for _, pj := range pjs.Items {
+		if pj.Status.State == prowapi.TriggeredState {
+			r = append(r, pj.Spec.Job)
+		}
+	}
+	if n := len(pjs.Items) - existing; n != len(r) {
+		t.Errorf("Expected %d new ProwJobs triggered, but got %d", n, len(r))
+	}
+	if len(r) == 0 {
+		return nil
+	}
+	return sets.NewString(r...).List()
+}
+
+func TestCreateRefs(t *testing.T) {
+	expected := prowapi.Refs{
+		Org:      testOrg,
+		Repo:     testRepo,
+		RepoLink: "https://gitee.com/org/repo",
+		BaseRef:  "master",
+		BaseSHA:  "base-sha",
+		BaseLink: "https://gitee.com/org/repo/commit/base-sha",
+		Pulls: []prowapi.Pull{{
+			Number:     testNumber,
+			Author:     "alice",
+			SHA:        testHeadSHA,
+			Ref:        "feature",
+			Link:       "https://gitee.com/org/repo/pulls/5",
+			CommitLink: "https://gitee.com/org/repo/pulls/5/commits",
+			AuthorLink: "https://gitee.com/alice",
+		}},
+	}
+
+	if actual := createRefs(makePR("alice")); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected refs %#v, but got %#v", expected, actual)
+	}
+}
+
+func TestHandleNoteEvent(t *testing.T) {
+	ps := testPresubmits(t)
+
+	testcases := []struct {
+		name      string
+		body      string
+		commenter string
+		prLabels  []string
+		// existing are the states of ProwJobs of the presubmits at the
+		// head of pull request.
+		existing map[string]prowapi.ProwJobState
+		// checkErr fails checking whether the commenter is trusted.
+		checkErr bool
+
+		expectedJobs    []string
+		expectedRemoved []string
+		expectedComment string
+	}{
+		{
+			name:      "plain comment",
+			body:      "looks good",
+			commenter: "member",
+		},
+		{
+			name:         "test a job",
+			body:         "/test job-c",
+			commenter:    "member",
+			expectedJobs: []string{"job-c"},
+		},
+		{
+			name:         "test all jobs",
+			body:         "/test all",
+			commenter:    "member",
+			expectedJobs: []string{"job-a", "job-b"},
+		},
+		{
+			name:            "test an unknown job",
+			body:            "/test job-x",
+			commenter:       "member",
+			expectedComment: "were not found",
+		},
+		{
+			name:      "retest the failed jobs",
+			body:      "/retest",
+			commenter: "member",
+			existing: map[string]prowapi.ProwJobState{
+				"job-a": prowapi.FailureState,
+				"job-b": prowapi.SuccessState,
+			},
+			expectedJobs: []string{"job-a"},
+		},
+		{
+			name:      "retest the jobs which have not run",
+			body:      "/retest",
+			commenter: "member",
+			existing: map[string]prowapi.ProwJobState{
+				"job-a": prowapi.SuccessState,
+			},
+			expectedJobs: []string{"job-b"},
+		},
+		{
+			name:         "untrusted user tests a trusted pull request",
+			body:         "/test job-a",
+			commenter:    "stranger",
+			expectedJobs: []string{"job-a"},
+		},
+		{
+			name:         "tests a trusted pull request when the trust can't be checked",
+			body:         "/test job-a",
+			commenter:    "stranger",
+			checkErr:     true,
+			expectedJobs: []string{"job-a"},
+		},
+		{
+			name:      "untrusted user tests a pull request needing ok-to-test",
+			body:      "/test all",
+			commenter: "stranger",
+			prLabels:  []string{labels.NeedsOkToTest},
+		},
+		{
+			name:            "untrusted user comments ok-to-test",
+			body:            "/ok-to-test",
+			commenter:       "stranger",
+			prLabels:        []string{labels.NeedsOkToTest},
+			expectedComment: "Only the members of org",
+		},
+		{
+			name:            "trusted user comments ok-to-test",
+			body:            "/ok-to-test",
+			commenter:       "trusted",
+			prLabels:        []string{labels.NeedsOkToTest},
+			expectedJobs:    []string{"job-a", "job-b"},
+			expectedRemoved: []string{labels.NeedsOkToTest},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := makePR("contributor", tc.prLabels...)
+
+			var objs []runtime.Object
+			for _, job := range ps {
+				if s, ok := tc.existing[job.Name]; ok {
+					objs = append(objs, makeProwJob(job, pr, s))
+				}
+			}
+
+			gc := &fakeGiteeClient{
+				members:         sets.NewString("member"),
+				collaborators:   sets.NewString(),
+				collaboratorErr: tc.checkErr,
+				listErr:         tc.checkErr,
+			}
+			p, pjc := newTestTrigger(t, gc, []string{"trusted"}, objs...)
+
+			action := "comment"
+			noteableType := "PullRequest"
+			e := &sdk.NoteEvent{
+				Action:       &action,
+				NoteableType: &noteableType,
+				Comment: &sdk.NoteHook{
+					Body: tc.body,
+					User: &sdk.UserHook{Login: tc.commenter},
+				},
+				PullRequest: pr,
+			}
+			if err := p.handleNoteEvent(e, logrus.WithField("plugin", pluginName)); err != nil {
+				t.Fatalf("Handling note event: %v", err)
+			}
+
+			if jobs := createdJobs(t, pjc, len(objs)); !reflect.DeepEqual(jobs, tc.expectedJobs) {
+				t.Errorf("Expected jobs %v, but got %v", tc.expectedJobs, jobs)
+			}
+			if !reflect.DeepEqual(gc.removed, tc.expectedRemoved) {
+				t.Errorf("Expected removed labels %v, but got %v", tc.expectedRemoved, gc.removed)
+			}
+			checkComment(t, gc.comments, tc.expectedComment)
+		})
+	}
+}
+
+func TestHandlePullRequestEvent(t *testing.T) {
+	testcases := []struct {
+		name       string
+		action     string
+		actionDesc string
+		author     string
+		prLabels   []string
+		gc         *fakeGiteeClient
+
+		expectedJobs    []string
+		expectedAdded   []string
+		expectedRemoved []string
+		expectedComment string
+	}{
+		{
+			name:         "opened by a member",
+			action:       "open",
+			author:       "member",
+			gc:           &fakeGiteeClient{},
+			expectedJobs: []string{"job-a", "job-b"},
+		},
+		{
+			name:         "opened by a trusted user",
+			action:       "open",
+			author:       "trusted",
+			gc:           &fakeGiteeClient{},
+			expectedJobs: []string{"job-a", "job-b"},
+		},
+		{
+			name:            "opened by an untrusted user",
+			action:          "open",
+			author:          "stranger",
+			gc:              &fakeGiteeClient{},
+			expectedAdded:   []string{labels.NeedsOkToTest},
+			expectedComment: "/ok-to-test",
+		},
+		{
+			name:            "new commits of an untrusted user need ok-to-test again",
+			action:          "update",
+			actionDesc:      "source_branch_changed",
+			author:          "stranger",
+			gc:              &fakeGiteeClient{},
+			expectedAdded:   []string{labels.NeedsOkToTest},
+			expectedComment: "/ok-to-test",
+		},
+		{
+			name:       "new commits of a pull request needing ok-to-test",
+			action:     "update",
+			actionDesc: "source_branch_changed",
+			author:     "stranger",
+			prLabels:   []string{labels.NeedsOkToTest},
+			gc:         &fakeGiteeClient{},
+		},
+		{
+			name:            "the author becomes trusted",
+			action:          "update",
+			actionDesc:      "source_branch_changed",
+			author:          "member",
+			prLabels:        []string{labels.NeedsOkToTest},
+			gc:              &fakeGiteeClient{},
+			expectedJobs:    []string{"job-a", "job-b"},
+			expectedRemoved: []string{labels.NeedsOkToTest},
+		},
+		{
+			name:   "collaborator is found by listing when checking fails",
+			action: "open",
+			author: "collaborator",
+			gc: &fakeGiteeClient{
+				collaboratorErr: true,
+			},
+			expectedJobs: []string{"job-a", "job-b"},
+		},
+		{
+			name:   "fails closed when the collaborator can't be checked",
+			action: "open",
+			author: "collaborator",
+			gc: &fakeGiteeClient{
+				collaboratorErr: true,
+				listErr:         true,
+			},
+			expectedAdded:   []string{labels.NeedsOkToTest},
+			expectedComment: "/ok-to-test",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			gc := tc.gc
+			gc.members = sets.NewString("member")
+			gc.collaborators = sets.NewString("collaborator")
+			p, pjc := newTestTrigger(t, gc, []string{"trusted"})
+
+			e := &sdk.PullRequestEvent{
+				Action:      &tc.action,
+				ActionDesc:  &tc.actionDesc,
+				PullRequest: makePR(tc.author, tc.prLabels...),
+			}
+			if err := p.handlePullRequestEvent(e, logrus.WithField("plugin", pluginName)); err != nil {
+				t.Fatalf("Handling pull request event: %v", err)
+			}
+
+			if jobs := createdJobs(t, pjc, 0); !reflect.DeepEqual(jobs, tc.expectedJobs) {
+				t.Errorf("Expected jobs %v, but got %v", tc.expectedJobs, jobs)
+			}
+			if !reflect.DeepEqual(gc.added, tc.expectedAdded) {
+				t.Errorf("Expected added labels %v, but got %v", tc.expectedAdded, gc.added)
+			}
+			if !reflect.DeepEqual(gc.removed, tc.expectedRemoved) {
+				t.Errorf("Expected removed labels %v, but got %v", tc.expectedRemoved, gc.removed)
+			}
+			checkComment(t, gc.comments, tc.expectedComment)
+		})
+	}
+}
+
+// checkComment checks that there is one comment containing expected, or no
+// comment if expected is empty.
+func checkComment(t *testing.T, comments []string, expected string) {
+	if expected == "" {
+		if len(comments) > 0 {
+			t.Errorf("Expected no comment, but got %v", comments)
+		}
+		return
+	}
+
+	if len(comments) != 1 || !strings.Contains(comments[0], expected) {
+		t.Errorf("Expected a comment containing %q, but got %v", expected, comments)
+	}
+}
@@ -0,0 +1,5 @@
+package updateconfig
+
+type giteeClient interface {
+	CreatePRComment(owner, repo string, number int, comment string) error
+}
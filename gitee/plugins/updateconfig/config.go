@@ -0,0 +1,133 @@
+package updateconfig
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/huaweicloud/golangsdk"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+type configuration struct {
+	ConfigUpdater []pluginConfig `json:"config_updater,omitempty"`
+}
+
+func (c *configuration) Validate() error {
+	if _, err := golangsdk.BuildRequestBody(c, ""); err != nil {
+		return err
+	}
+
+	for i := range c.ConfigUpdater {
+		if err := c.ConfigUpdater[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *configuration) SetDefault() {
+}
+
+// ConfigFor returns the config for the repo. The config of repo takes
+// precedence over the one of its org.
+func (c *configuration) ConfigFor(org, repo string) *pluginConfig {
+	i := plugins.FindConfig(org, repo, len(c.ConfigUpdater), func(i int) []string {
+		return c.ConfigUpdater[i].Repos
+	})
+	if i < 0 {
+		return nil
+	}
+	return &(c.ConfigUpdater[i])
+}
+
+type pluginConfig struct {
+	// Repos is either of the form org/repos or just org.
+	Repos []string `json:"repos" required:"true"`
+
+	// Branch is the branch whose pushes update the config.
+	// Defaults to the default branch of repo.
+	Branch string `json:"branch,omitempty"`
+
+	// Maps is a map of the glob of files in repo to where they are updated,
+	// such as "config/plugins/*.yaml". The file matched by several globs is
+	// updated to the target of the first one in lexical order.
+	Maps map[string]updateTarget `json:"maps" required:"true"`
+}
+
+func (p *pluginConfig) validate() error {
+	for glob, t := range p.Maps {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %v", glob, err)
+		}
+
+		if (t.Dir == "") == (t.ConfigMap == nil) {
+			return fmt.Errorf("%s: exactly one of dir and configmap must be set", glob)
+		}
+
+		if cm := t.ConfigMap; cm != nil && (cm.Name == "" || cm.Namespace == "") {
+			return fmt.Errorf("%s: name and namespace of configmap must be set", glob)
+		}
+	}
+	return nil
+}
+
+// target returns the target of the first glob in lexical order which the
+// file matches.
+func (p *pluginConfig) target(file string) *updateTarget {
+	globs := make([]string, 0, len(p.Maps))
+	for glob := range p.Maps {
+		globs = append(globs, glob)
+	}
+	sort.Strings(globs)
+
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, file); ok {
+			t := p.Maps[glob]
+			return &t
+		}
+	}
+	return nil
+}
+
+// mayChange reports whether the push may change the files matched by Maps.
+// The commits in push event are truncated, so it is true if not all of them
+// are included.
+func (p *pluginConfig) mayChange(e *sdk.PushEvent) bool {
+	if len(e.Commits) == 0 || int(e.TotalCommitsCount) > len(e.Commits) {
+		return true
+	}
+
+	for _, c := range e.Commits {
+		for _, files := range [][]string{c.Added, c.Modified, c.Removed} {
+			for _, f := range files {
+				if p.target(f) != nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// updateTarget is where the files are updated to. It is either a local
+// directory or a ConfigMap.
+type updateTarget struct {
+	// Dir is the local directory to which the files are written with their
+	// base names. It is usually mounted to the hook or other components.
+	Dir string `json:"dir,omitempty"`
+
+	// ConfigMap is the ConfigMap in which the files are stored.
+	ConfigMap *configMapSpec `json:"configmap,omitempty"`
+}
+
+type configMapSpec struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+
+	// Key is the key in the ConfigMap to update with the file contents.
+	// If no explicit key is given, the basename of the file will be used.
+	Key string `json:"key,omitempty"`
+}
@@ -0,0 +1,392 @@
+package updateconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	prowConfig "k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/git/v2"
+	"k8s.io/test-infra/prow/pluginhelp"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+const pluginName = "config-updater"
+
+var (
+	// Gitee writes the number of pull request to the message of merge commit,
+	// such as "!12 title" or "Merge pull request !12 from user/branch".
+	mergedPRRe = regexp.MustCompile(`(?m)(?:^|Merge pull request )!(\d+)\b`)
+
+	zeroSHA = strings.Repeat("0", 40)
+)
+
+type updateConfig struct {
+	getPluginConfig plugins.GetPluginConfig
+	gc              giteeClient
+	gitClient       git.ClientFactory
+	kc              corev1.ConfigMapsGetter
+}
+
+// NewUpdateConfig returns the config-updater plugin. kc can be nil if no
+// config is updated to ConfigMaps.
+func NewUpdateConfig(f plugins.GetPluginConfig, gc giteeClient, gitClient git.ClientFactory, kc corev1.ConfigMapsGetter) plugins.Plugin {
+	return &updateConfig{
+		getPluginConfig: f,
+		gc:              gc,
+		gitClient:       gitClient,
+		kc:              kc,
+	}
+}
+
+func (this *updateConfig) HelpProvider(_ []prowConfig.OrgRepo) (*pluginhelp.PluginHelp, error) {
+	return &pluginhelp.PluginHelp{
+		Description: "The config-updater plugin automatically redeploys the configuration files when they change. The plugin watches for the pushes to the configured branch that modify the configuration files, updates the local files or ConfigMaps in response and comments the result on the merged pull request.",
+	}, nil
+}
+
+func (this *updateConfig) PluginName() string {
+	return pluginName
+}
+
+func (this *updateConfig) NewPluginConfig() plugins.PluginConfig {
+	return &configuration{}
+}
+
+func (this *updateConfig) RegisterEventHandler(p plugins.Plugins) {
+	p.RegisterPushEventHandler(this.PluginName(), this.handlePushEvent)
+}
+
+// change is a changed file which is updated to a target.
+type change struct {
+	file string
+	// key is the key of ConfigMap to update with the file.
+	key     string
+	removed bool
+}
+
+type configMapID struct {
+	namespace string
+	name      string
+}
+
+func (this *updateConfig) handlePushEvent(e *sdk.PushEvent, log *logrus.Entry) error {
+	org := e.Repository.Namespace
+	repo := e.Repository.Path
+
+	cfg, err := this.orgRepoConfig(org, repo)
+	if err != nil {
+		return err
+	}
+
+	branch := cfg.Branch
+	if branch == "" {
+		branch = e.Repository.DefaultBranch
+	}
+	if strings.TrimPrefix(strOf(e.Ref), "refs/heads/") != branch {
+		return nil
+	}
+
+	before, after := strOf(e.Before), strOf(e.After)
+	if before == "" || before == zeroSHA || after == "" || after == zeroSHA {
+		log.Debug("Push creates or deletes the branch, skipping.")
+		return nil
+	}
+
+	if !cfg.mayChange(e) {
+		log.Debug("Push doesn't change the config files, skipping.")
+		return nil
+	}
+
+	r, err := this.gitClient.ClientFor(org, repo)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := r.Clean(); err != nil {
+			log.WithError(err).Error("Could not clean up git repo.")
+		}
+	}()
+
+	if err := r.Checkout(after); err != nil {
+		return err
+	}
+
+	files, err := r.Diff(after, before)
+	if err != nil {
+		return err
+	}
+
+	dirs := map[string][]change{}
+	configMaps := map[configMapID][]change{}
+	for _, f := range files {
+		t := cfg.target(f)
+		if t == nil {
+			continue
+		}
+
+		c := change{file: f}
+		if _, err := os.Stat(filepath.Join(r.Directory(), f)); os.IsNotExist(err) {
+			c.removed = true
+		}
+
+		if t.Dir != "" {
+			dirs[t.Dir] = append(dirs[t.Dir], c)
+			continue
+		}
+
+		cm := t.ConfigMap
+		c.key = cm.Key
+		if c.key == "" {
+			c.key = path.Base(f)
+		}
+		id := configMapID{namespace: cm.Namespace, name: cm.Name}
+		configMaps[id] = append(configMaps[id], c)
+	}
+
+	var updated []string
+	var errs []error
+
+	for _, dir := range sortedDirs(dirs) {
+		l := log.WithField("dir", dir)
+		if err := updateDir(r.Directory(), dir, dirs[dir]); err != nil {
+			l.WithError(err).Error("Failed to update the local directory.")
+			errs = append(errs, err)
+			continue
+		}
+		l.Info("Updated the local directory.")
+		updated = append(updated, message(fmt.Sprintf("local directory `%s`", dir), dirs[dir], false))
+	}
+
+	for _, id := range sortedConfigMaps(configMaps) {
+		l := log.WithFields(logrus.Fields{"configmap": id.name, "namespace": id.namespace})
+		if err := this.updateConfigMap(r.Directory(), id, configMaps[id]); err != nil {
+			l.WithError(err).Error("Failed to update the ConfigMap.")
+			errs = append(errs, err)
+			continue
+		}
+		l.Info("Updated the ConfigMap.")
+		updated = append(updated, message(fmt.Sprintf("`%s` configmap in namespace `%s`", id.name, id.namespace), configMaps[id], true))
+	}
+
+	if err := this.comment(e, updated, errs, log); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// comment comments the result on the pull request which is merged by the push.
+func (this *updateConfig) comment(e *sdk.PushEvent, updated []string, errs []error, log *logrus.Entry) error {
+	if len(updated) == 0 && len(errs) == 0 {
+		return nil
+	}
+
+	var number int
+	if e.HeadCommit != nil {
+		if m := mergedPRRe.FindStringSubmatch(e.HeadCommit.Message); m != nil {
+			number, _ = strconv.Atoi(m[1])
+		}
+	}
+	if number == 0 {
+		log.Info("The push is not a merge of pull request, skipping the comment.")
+		return nil
+	}
+
+	var msg string
+	switch n := len(updated); n {
+	case 0:
+	case 1:
+		msg = fmt.Sprintf("Updated the %s", updated[0])
+	default:
+		msg = fmt.Sprintf("Updated the following %d targets:\n", n)
+		for _, v := range updated {
+			msg += fmt.Sprintf(" * %s\n", v)
+		}
+	}
+
+	if len(errs) > 0 {
+		msg += "\nFailed to update the config:\n"
+		for _, err := range errs {
+			msg += fmt.Sprintf(" * %v\n", err)
+		}
+	}
+
+	return this.gc.CreatePRComment(e.Repository.Namespace, e.Repository.Path, number, msg)
+}
+
+func message(target string, changes []change, withKey bool) string {
+	msg := fmt.Sprintf("%s using the following files:", target)
+	for _, c := range changes {
+		switch {
+		case c.removed && withKey:
+			msg = fmt.Sprintf("%s\n - removed key `%s` of file `%s`", msg, c.key, c.file)
+		case c.removed:
+			msg = fmt.Sprintf("%s\n - removed file `%s`", msg, c.file)
+		case withKey:
+			msg = fmt.Sprintf("%s\n - key `%s` using file `%s`", msg, c.key, c.file)
+		default:
+			msg = fmt.Sprintf("%s\n - file `%s`", msg, c.file)
+		}
+	}
+	return msg
+}
+
+// updateDir writes the files in root to dir with their base names, or removes
+// them from dir if they are removed.
+func updateDir(root, dir string, changes []change) error {
+	for _, c := range changes {
+		dst := filepath.Join(dir, path.Base(c.file))
+
+		if c.removed {
+			if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(root, c.file))
+		if err != nil {
+			return err
+		}
+
+		// Write to a temporary file and rename it, so that the readers
+		// won't see a partial file.
+		tmp, err := ioutil.TempFile(dir, "."+path.Base(c.file))
+		if err != nil {
+			return err
+		}
+		_, err = tmp.Write(b)
+		if err1 := tmp.Close(); err == nil {
+			err = err1
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), dst)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *updateConfig) updateConfigMap(root string, id configMapID, changes []change) error {
+	if this.kc == nil {
+		return fmt.Errorf("no kubernetes client to update configmap %s/%s", id.namespace, id.name)
+	}
+
+	cmc := this.kc.ConfigMaps(id.namespace)
+
+	cm, err := cmc.Get(id.name, metav1.GetOptions{})
+	isNotFound := errors.IsNotFound(err)
+	if err != nil && !isNotFound {
+		return fmt.Errorf("failed to fetch current state of configmap: %v", err)
+	}
+
+	if isNotFound {
+		cm = &coreapi.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      id.name,
+				Namespace: id.namespace,
+			},
+		}
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+
+	for _, c := range changes {
+		if c.removed {
+			delete(cm.Data, c.key)
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(root, c.file))
+		if err != nil {
+			return err
+		}
+		cm.Data[c.key] = string(b)
+	}
+
+	if isNotFound {
+		_, err = cmc.Create(cm)
+	} else {
+		_, err = cmc.Update(cm)
+	}
+	if err != nil {
+		return fmt.Errorf("update configmap %s/%s err: %v", id.namespace, id.name, err)
+	}
+	return nil
+}
+
+func (this *updateConfig) orgRepoConfig(org, repo string) (*pluginConfig, error) {
+	cfg, err := this.pluginConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	pc := cfg.ConfigFor(org, repo)
+	if pc == nil {
+		return nil, fmt.Errorf("no config-updater plugin config for this repo:%s/%s", org, repo)
+	}
+
+	return pc, nil
+}
+
+func (this *updateConfig) pluginConfig() (*configuration, error) {
+	c := this.getPluginConfig(this.PluginName())
+	if c == nil {
+		return nil, fmt.Errorf("can't find the configuration")
+	}
+
+	c1, ok := c.(*configuration)
+	if !ok {
+		return nil, fmt.Errorf("can't convert to configuration")
+	}
+
+	return c1, nil
+}
+
+func sortedDirs(m map[string][]change) []string {
+	r := make([]string, 0, len(m))
+	for k := range m {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+func sortedConfigMaps(m map[configMapID][]change) []configMapID {
+	r := make([]configMapID, 0, len(m))
+	for k := range m {
+		r = append(r, k)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].namespace != r[j].namespace {
+			return r[i].namespace < r[j].namespace
+		}
+		return r[i].name < r[j].name
+	})
+	return r
+}
+
+func strOf(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
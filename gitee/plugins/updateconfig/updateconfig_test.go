@@ -0,0 +1,315 @@
+package updateconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	sdk "gitee.com/openeuler/go-gitee/gitee"
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/test-infra/prow/git/localgit"
+
+	"github.com/opensourceways/yabot/gitee/plugins"
+)
+
+const (
+	testOrg    = "org"
+	testRepo   = "repo"
+	testBranch = "release"
+)
+
+type fakeGiteeClient struct {
+	comments map[int][]string
+}
+
+func (f *fakeGiteeClient) CreatePRComment(owner, repo string, number int, comment string) error {
+	if f.comments == nil {
+		f.comments = map[int][]string{}
+	}
+	f.comments[number] = append(f.comments[number], comment)
+	return nil
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestHandlePushEvent(t *testing.T) {
+	testcases := []struct {
+		name string
+		// initial are the files in the branch before the push.
+		initial map[string][]byte
+		// added and removed are the files changed by the push.
+		added   map[string][]byte
+		removed []string
+		ref     string
+		// pushed are the files listed in the commits of push event. The
+		// commits are truncated if it is nil.
+		pushed []string
+		// existingData and existingFiles are the content of ConfigMap and
+		// local directory before the push.
+		existingData  map[string]string
+		existingFiles map[string]string
+
+		expectedData    map[string]string
+		expectedFiles   map[string]string
+		expectedComment bool
+	}{
+		{
+			name: "updates the ConfigMap and the local directory",
+			initial: map[string][]byte{
+				"config/a.yaml": []byte("a: 1"),
+			},
+			added: map[string][]byte{
+				"config/a.yaml": []byte("a: 2"),
+				"config/b.yaml": []byte("b: 1"),
+				"local/c.yaml":  []byte("c: 1"),
+				"other/d.yaml":  []byte("d: 1"),
+			},
+			ref:          "refs/heads/" + testBranch,
+			existingData: map[string]string{"a.yaml": "a: 1", "x.yaml": "x: 1"},
+			expectedData: map[string]string{
+				"a.yaml": "a: 2",
+				"b.yaml": "b: 1",
+				"x.yaml": "x: 1",
+			},
+			expectedFiles:   map[string]string{"c.yaml": "c: 1"},
+			expectedComment: true,
+		},
+		{
+			name: "creates the ConfigMap if it doesn't exist",
+			added: map[string][]byte{
+				"config/a.yaml": []byte("a: 1"),
+			},
+			ref:             "refs/heads/" + testBranch,
+			expectedData:    map[string]string{"a.yaml": "a: 1"},
+			expectedFiles:   map[string]string{},
+			expectedComment: true,
+		},
+		{
+			name: "removes the keys and files of removed files",
+			initial: map[string][]byte{
+				"config/a.yaml": []byte("a: 1"),
+				"local/c.yaml":  []byte("c: 1"),
+			},
+			removed:         []string{"config/a.yaml", "local/c.yaml"},
+			ref:             "refs/heads/" + testBranch,
+			existingData:    map[string]string{"a.yaml": "a: 1", "x.yaml": "x: 1"},
+			existingFiles:   map[string]string{"c.yaml": "c: 1", "y.yaml": "y: 1"},
+			expectedData:    map[string]string{"x.yaml": "x: 1"},
+			expectedFiles:   map[string]string{"y.yaml": "y: 1"},
+			expectedComment: true,
+		},
+		{
+			name: "skips the push whose commits don't change the config files",
+			added: map[string][]byte{
+				"config/a.yaml": []byte("a: 2"),
+				"other/d.yaml":  []byte("d: 1"),
+			},
+			ref:           "refs/heads/" + testBranch,
+			pushed:        []string{"other/d.yaml"},
+			existingData:  map[string]string{"a.yaml": "a: 1"},
+			expectedData:  map[string]string{"a.yaml": "a: 1"},
+			expectedFiles: map[string]string{},
+		},
+		{
+			name: "updates by the push whose commits change the config files",
+			added: map[string][]byte{
+				"config/a.yaml": []byte("a: 2"),
+				"other/d.yaml":  []byte("d: 1"),
+			},
+			ref:             "refs/heads/" + testBranch,
+			pushed:          []string{"config/a.yaml", "other/d.yaml"},
+			existingData:    map[string]string{"a.yaml": "a: 1"},
+			expectedData:    map[string]string{"a.yaml": "a: 2"},
+			expectedFiles:   map[string]string{},
+			expectedComment: true,
+		},
+		{
+			name: "ignores the push to other branches",
+			added: map[string][]byte{
+				"config/a.yaml": []byte("a: 2"),
+				"local/c.yaml":  []byte("c: 1"),
+			},
+			ref:           "refs/heads/dev",
+			existingData:  map[string]string{"a.yaml": "a: 1"},
+			expectedData:  map[string]string{"a.yaml": "a: 1"},
+			expectedFiles: map[string]string{},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			lg, gitClient, err := localgit.NewV2()
+			if err != nil {
+				t.Fatalf("Making local git repo: %v", err)
+			}
+			defer func() {
+				if err := lg.Clean(); err != nil {
+					t.Errorf("Cleaning up localgit: %v", err)
+				}
+				if err := gitClient.Clean(); err != nil {
+					t.Errorf("Cleaning up client: %v", err)
+				}
+			}()
+
+			if err := lg.MakeFakeRepo(testOrg, testRepo); err != nil {
+				t.Fatalf("Making fake repo: %v", err)
+			}
+			if err := lg.CheckoutNewBranch(testOrg, testRepo, testBranch); err != nil {
+				t.Fatalf("Checking out branch: %v", err)
+			}
+			if len(tc.initial) > 0 {
+				if err := lg.AddCommit(testOrg, testRepo, tc.initial); err != nil {
+					t.Fatalf("Adding initial commit: %v", err)
+				}
+			}
+			before, err := lg.RevParse(testOrg, testRepo, "HEAD")
+			if err != nil {
+				t.Fatalf("Getting the commit before push: %v", err)
+			}
+
+			if len(tc.added) > 0 {
+				if err := lg.AddCommit(testOrg, testRepo, tc.added); err != nil {
+					t.Fatalf("Adding commit: %v", err)
+				}
+			}
+			if len(tc.removed) > 0 {
+				if err := lg.RmCommit(testOrg, testRepo, tc.removed); err != nil {
+					t.Fatalf("Removing files: %v", err)
+				}
+			}
+			after, err := lg.RevParse(testOrg, testRepo, "HEAD")
+			if err != nil {
+				t.Fatalf("Getting the commit after push: %v", err)
+			}
+
+			dir, err := ioutil.TempDir("", "config-updater")
+			if err != nil {
+				t.Fatalf("Making the local directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			for f, c := range tc.existingFiles {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("Writing the existing file: %v", err)
+				}
+			}
+
+			kc := fake.NewSimpleClientset()
+			if tc.existingData != nil {
+				kc = fake.NewSimpleClientset(&coreapi.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "prow"},
+					Data:       tc.existingData,
+				})
+			}
+
+			cfg := &configuration{
+				ConfigUpdater: []pluginConfig{{
+					Repos: []string{testOrg},
+					Maps: map[string]updateTarget{
+						"config/*.yaml": {ConfigMap: &configMapSpec{Name: "config", Namespace: "prow"}},
+						"local/*.yaml":  {Dir: dir},
+					},
+				}},
+			}
+			gc := &fakeGiteeClient{}
+			p := NewUpdateConfig(func(string) plugins.PluginConfig { return cfg }, gc, gitClient, kc.CoreV1())
+
+			e := &sdk.PushEvent{
+				Ref:    strPtr(tc.ref),
+				Before: strPtr(before),
+				After:  strPtr(after),
+				HeadCommit: &sdk.CommitHook{
+					Message: "!12 update config",
+				},
+				Repository: &sdk.ProjectHook{
+					Namespace:     testOrg,
+					Path:          testRepo,
+					DefaultBranch: testBranch,
+				},
+			}
+			if tc.pushed != nil {
+				e.Commits = []sdk.CommitHook{{Modified: tc.pushed}}
+				e.TotalCommitsCount = 1
+			}
+			if err := p.(*updateConfig).handlePushEvent(e, logrus.WithField("plugin", pluginName)); err != nil {
+				t.Fatalf("Handling push event: %v", err)
+			}
+
+			data := map[string]string{}
+			cm, err := kc.CoreV1().ConfigMaps("prow").Get("config", metav1.GetOptions{})
+			if err == nil {
+				data = cm.Data
+			} else if tc.expectedData != nil {
+				t.Fatalf("Getting the ConfigMap: %v", err)
+			}
+			if !reflect.DeepEqual(data, tc.expectedData) {
+				t.Errorf("Expected the data of ConfigMap %v, but got %v", tc.expectedData, data)
+			}
+
+			files := map[string]string{}
+			fs, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("Reading the local directory: %v", err)
+			}
+			for _, f := range fs {
+				b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+				if err != nil {
+					t.Fatalf("Reading the local file: %v", err)
+				}
+				files[f.Name()] = string(b)
+			}
+			if !reflect.DeepEqual(files, tc.expectedFiles) {
+				t.Errorf("Expected the local files %v, but got %v", tc.expectedFiles, files)
+			}
+
+			comments := gc.comments[12]
+			if tc.expectedComment != (len(comments) == 1) {
+				t.Errorf("Expected comment: %t, but got %v", tc.expectedComment, comments)
+			}
+			if len(comments) == 1 && strings.Contains(comments[0], "Failed") {
+				t.Errorf("Unexpected failure in comment: %s", comments[0])
+			}
+		})
+	}
+}
+
+func TestTarget(t *testing.T) {
+	cfg := &pluginConfig{
+		Maps: map[string]updateTarget{
+			"config/*.yaml": {Dir: "all"},
+			"config/a*":     {Dir: "a"},
+			"config/b.yaml": {Dir: "b"},
+		},
+	}
+
+	testcases := []struct {
+		file     string
+		expected string
+	}{
+		{file: "config/a.yaml", expected: "all"},
+		{file: "config/b.yaml", expected: "all"},
+		{file: "config/a.json", expected: "a"},
+		{file: "other/a.yaml"},
+	}
+
+	for _, tc := range testcases {
+		// The globs are iterated in random order, so match several times.
+		for i := 0; i < 10; i++ {
+			dir := ""
+			if v := cfg.target(tc.file); v != nil {
+				dir = v.Dir
+			}
+			if dir != tc.expected {
+				t.Fatalf("%s: expected target %q, but got %q", tc.file, tc.expected, dir)
+			}
+		}
+	}
+}
@@ -1,12 +1,61 @@
 package plugins
 
 import (
+	"fmt"
 	"strings"
 
 	"gitee.com/openeuler/go-gitee/gitee"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/github"
 )
 
+// FindConfig returns the index of the config for the repo among n configs,
+// whose orgs and repos are returned by reposOf. The config of repo takes
+// precedence over the one of its org. It is -1 if neither is found.
+func FindConfig(org, repo string, n int, reposOf func(int) []string) int {
+	fullName := fmt.Sprintf("%s/%s", org, repo)
+
+	index := -1
+	for i := 0; i < n; i++ {
+		s := sets.NewString(reposOf(i)...)
+		if s.Has(fullName) {
+			return i
+		}
+
+		if s.Has(org) {
+			index = i
+		}
+	}
+	return index
+}
+
+// CollaboratorClient checks the collaborators of repo.
+type CollaboratorClient interface {
+	IsCollaborator(owner, repo, login string) (bool, error)
+	ListCollaborators(org, repo string) ([]github.User, error)
+}
+
+// IsCollaborator checks whether the user is a collaborator of repo. It falls
+// back to list the collaborators if gitee fails to check the collaborator
+// directly, which happens sometimes.
+func IsCollaborator(c CollaboratorClient, owner, repo, login string) (bool, error) {
+	b, err := c.IsCollaborator(owner, repo, login)
+	if err == nil {
+		return b, nil
+	}
+
+	cs, err1 := c.ListCollaborators(owner, repo)
+	if err1 != nil {
+		return false, err
+	}
+	for _, u := range cs {
+		if github.NormLogin(u.Login) == github.NormLogin(login) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func NoteEventToCommentEvent(e *gitee.NoteEvent) github.GenericCommentEvent {
 	gc := github.GenericCommentEvent{
 		Repo: github.Repo{
@@ -0,0 +1,89 @@
+package externalplugins
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader is the header carrying the HMAC signature of the event
+	// which the hook sends to the external plugin.
+	SignatureHeader = "X-Hook-Signature"
+	// TimestampHeader is the header carrying the unix time at which the hook
+	// signed the event.
+	TimestampHeader = "X-Hook-Timestamp"
+
+	signaturePrefix = "sha256="
+)
+
+// DefaultMaxSkew is the default value of how old a signed request can be.
+const DefaultMaxSkew = 5 * time.Minute
+
+// PayloadSignature returns the signature of payload sent at timestamp.
+// It is the hex encoded HMAC-SHA256 of "timestamp.payload" computed with
+// the secret shared between the hook and the external plugin.
+func PayloadSignature(secret []byte, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest sets the signature and timestamp headers for the payload.
+func SignRequest(h http.Header, secret []byte, payload []byte) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	h.Set(TimestampHeader, ts)
+	h.Set(SignatureHeader, PayloadSignature(secret, ts, payload))
+}
+
+// ValidatePayload checks that the payload is signed by the hook with secret
+// and that the signature is not older than maxSkew.
+func ValidatePayload(h http.Header, payload []byte, secret []byte, maxSkew time.Duration) error {
+	sig := h.Get(SignatureHeader)
+	if sig == "" {
+		return fmt.Errorf("missing %s header", SignatureHeader)
+	}
+
+	ts := h.Get(TimestampHeader)
+	if ts == "" {
+		return fmt.Errorf("missing %s header", TimestampHeader)
+	}
+
+	t, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header: %v", TimestampHeader, err)
+	}
+
+	if d := time.Since(time.Unix(t, 0)); d > maxSkew || d < -maxSkew {
+		return errors.New("the signature has expired")
+	}
+
+	if !hmac.Equal([]byte(sig), []byte(PayloadSignature(secret, ts, payload))) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// ValidateRequest reads the body of request and validates it by
+// ValidatePayload. It returns the body if it is valid.
+func ValidateRequest(r *http.Request, secret []byte, maxSkew time.Duration) ([]byte, error) {
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidatePayload(r.Header, payload, secret, maxSkew); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
@@ -35,6 +35,7 @@ import (
 	"k8s.io/test-infra/prow/git/v2"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/pluginhelp"
+	origin "k8s.io/test-infra/prow/plugins"
 	"k8s.io/test-infra/prow/repoowners"
 	"k8s.io/test-infra/prow/slack"
 	"sigs.k8s.io/yaml"
@@ -444,14 +445,9 @@ func EventsForPlugin(name string) []string {
 	return events
 }
 
-var configMapSizeGauges = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-	Name: "prow_configmap_size_bytes",
-	Help: "Size of data fields in ConfigMaps updated automatically by Prow in bytes.",
-}, []string{"name", "namespace"})
-
-func init() {
-	prometheus.MustRegister(configMapSizeGauges)
-}
+// configMapSizeGauges is shared with the plugins of test-infra, which are
+// linked together and register "prow_configmap_size_bytes" already.
+var configMapSizeGauges = origin.NewMetrics().ConfigMapGauges
 
 // Metrics is a set of metrics that are gathered by plugins.
 // It is up the the consumers of these metrics to ensure that they